@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/visagoapi/generic"
+
 	"github.com/mitchellh/go-homedir"
 	"github.com/zquestz/go-ucl"
 )
@@ -17,10 +20,80 @@ type Config struct {
 	Verbose        bool     `json:"verbose,string"`
 	Whitelist      []string `json:"whitelist"`
 	JSONOutput     bool     `json:"json_output,string"`
+	Output         string   `json:"output"`
+	Wide           bool     `json:"wide,string"`
+	TopTags        int      `json:"top_tags,string"`
+	Format         string   `json:"format"`
+	TemplateFile   string   `json:"template_file"`
+	WriteXMP       bool     `json:"write_xmp,string"`
+	XMPRoot        string   `json:"xmp_root"`
 	TagScore       float64  `json:"tag_score,string"`
 	Tags           bool     `json:"tags,string"`
 	Faces          bool     `json:"faces,string"`
 	Colors         bool     `json:"colors,string"`
+	Web            bool     `json:"web,string"`
+	Captions       bool     `json:"captions,string"`
+	Codes          bool     `json:"codes,string"`
+	Hashes         bool     `json:"hashes,string"`
+	Location       bool     `json:"location,string"`
+	Metadata       bool     `json:"metadata,string"`
+	Quality        bool     `json:"quality,string"`
+	Concurrency    int      `json:"concurrency,string"`
+
+	CropAspectRatios []string `json:"crop_aspect_ratios"`
+	CropOutputDir    string   `json:"crop_output_dir"`
+
+	DupesDistance int    `json:"dupes_distance,string"`
+	DupesHash     string `json:"dupes_hash"`
+
+	EmbedOutputDir string `json:"embed_output_dir"`
+	EmbedDryRun    bool   `json:"embed_dry_run,string"`
+	EmbedBackup    bool   `json:"embed_backup,string"`
+
+	GoogleVision GoogleVisionConfig `json:"googlevision"`
+
+	// Plugins stores plugin specific settings keyed by plugin name.
+	Plugins map[string]map[string]string `json:"plugins"`
+
+	// Generic defines generic HTTP plugins keyed by plugin name.
+	Generic map[string]*generic.Config `json:"generic"`
+}
+
+// GoogleVisionConfig stores settings specific to the googlevision plugin.
+type GoogleVisionConfig struct {
+	MaxResults MaxResultsConfig `json:"max_results"`
+}
+
+// MaxResultsConfig stores the maximum number of results per feature.
+// Zero leaves the provider default in place.
+type MaxResultsConfig struct {
+	Tags   int64 `json:"tags,string"`
+	Colors int64 `json:"colors,string"`
+	Faces  int64 `json:"faces,string"`
+	Web    int64 `json:"web,string"`
+}
+
+// Map returns the configured limits keyed by visagoapi feature.
+func (m MaxResultsConfig) Map() map[string]int64 {
+	limits := make(map[string]int64)
+
+	if m.Tags > 0 {
+		limits[visagoapi.TagsFeature] = m.Tags
+	}
+
+	if m.Colors > 0 {
+		limits[visagoapi.ColorsFeature] = m.Colors
+	}
+
+	if m.Faces > 0 {
+		limits[visagoapi.FacesFeature] = m.Faces
+	}
+
+	if m.Web > 0 {
+		limits[visagoapi.WebFeature] = m.Web
+	}
+
+	return limits
 }
 
 // Load reads the configuration from ~/.visago/config and loads it into the Config struct.
@@ -42,13 +115,23 @@ func (c *Config) Load() error {
 	return nil
 }
 
-func (c *Config) loadConfig() ([]byte, error) {
+// configDir returns the visago configuration directory.
+func configDir() (string, error) {
 	h, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(h, ".visago"), nil
+}
+
+func (c *Config) loadConfig() ([]byte, error) {
+	dir, err := configDir()
 	if err != nil {
 		return nil, err
 	}
 
-	f, err := os.Open(filepath.Join(h, ".visago", "config"))
+	f, err := os.Open(filepath.Join(dir, "config"))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	cropMethodHints        = "crop_hints"
+	cropMethodFaces        = "faces"
+	cropMethodObjects      = "objects"
+	cropMethodFacesObjects = "faces_objects"
+	cropMethodCenter       = "center"
+
+	// Crop hints are matched to a requested aspect
+	// ratio when they are within this tolerance.
+	aspectRatioTolerance = 0.01
+)
+
+// CropCmd writes cropped copies of local images.
+var CropCmd = &cobra.Command{
+	Use:   "crop <files>",
+	Short: "Crop images to aspect ratios",
+	Long: `Crop images to aspect ratios using crop hints from the Google Vision plugin.
+When crop hints are not available the crop covers the detected faces and
+objects, and when there are neither it is centered on the image.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := cropCommand(cmd, args)
+		if err != nil {
+			bail(err)
+		}
+	},
+}
+
+type aspectRatio struct {
+	Label string
+	Value float64
+}
+
+type cropResult struct {
+	File        string    `json:"file"`
+	AspectRatio string    `json:"aspect_ratio"`
+	Method      string    `json:"method,omitempty"`
+	Rect        *cropRect `json:"rect,omitempty"`
+	Output      string    `json:"output,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type cropRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func prepareCropFlags() {
+	CropCmd.Flags().StringSliceVarP(
+		&config.CropAspectRatios, "aspect-ratio", "a", config.CropAspectRatios, "target aspect ratios (e.g. 16:9,1:1)")
+	CropCmd.Flags().StringVarP(
+		&config.CropOutputDir, "output-dir", "o", config.CropOutputDir, "directory for cropped images")
+}
+
+func cropCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		help := cmd.HelpFunc()
+		help(cmd, args)
+
+		return nil
+	}
+
+	ratios, err := parseAspectRatios(config.CropAspectRatios)
+	if err != nil {
+		return err
+	}
+
+	if len(ratios) == 0 {
+		return fmt.Errorf("must supply at least one aspect ratio")
+	}
+
+	files := []string{}
+	for _, item := range args {
+		fi, err := os.Stat(item)
+		if err != nil || fi.IsDir() {
+			util.SmartPrint("warn", fmt.Sprintf("%q is not a local file\n", item), config.JSONOutput)
+			continue
+		}
+
+		files = append(files, item)
+	}
+
+	if len(files) == 0 {
+		util.SmartPrint("error", "failed to find any valid files\n", config.JSONOutput)
+		return nil
+	}
+
+	visagoapi.SetBlacklist(config.Blacklist)
+	visagoapi.SetWhitelist(config.Whitelist)
+
+	aspectRatios := []float64{}
+	for _, r := range ratios {
+		aspectRatios = append(aspectRatios, r.Value)
+	}
+
+	pluginConfig := &visagoapi.PluginConfig{
+		Files:        files,
+		Verbose:      config.Verbose,
+		Features:     []string{visagoapi.CropHintsFeature, visagoapi.FacesFeature, visagoapi.ObjectsFeature},
+		AspectRatios: aspectRatios,
+		Options:      config.Plugins,
+		Concurrency:  config.Concurrency,
+	}
+
+	output, err := visagoapi.FetchResults(pluginConfig)
+	if err != nil {
+		return err
+	}
+
+	assets := make(map[string]*visagoapi.Asset)
+	if all, ok := output["all"]; ok {
+		if config.Verbose {
+			for _, e := range all.Errors {
+				util.SmartPrint("warn", fmt.Sprintf("%s\n", e), config.JSONOutput)
+			}
+		}
+
+		for _, asset := range all.Assets {
+			assets[asset.Name] = asset
+		}
+	}
+
+	results := []*cropResult{}
+	for _, file := range files {
+		results = append(results, cropFile(file, assets[file], ratios)...)
+	}
+
+	displayCropResults(results)
+
+	return nil
+}
+
+func parseAspectRatios(values []string) ([]*aspectRatio, error) {
+	ratios := []*aspectRatio{}
+
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		var ratio float64
+
+		parts := strings.Split(v, ":")
+		switch len(parts) {
+		case 1:
+			r, err := strconv.ParseFloat(parts[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid aspect ratio %q", v)
+			}
+
+			ratio = r
+		case 2:
+			w, err := strconv.ParseFloat(parts[0], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid aspect ratio %q", v)
+			}
+
+			h, err := strconv.ParseFloat(parts[1], 64)
+			if err != nil || h == 0 {
+				return nil, fmt.Errorf("invalid aspect ratio %q", v)
+			}
+
+			ratio = w / h
+		default:
+			return nil, fmt.Errorf("invalid aspect ratio %q", v)
+		}
+
+		if ratio <= 0 {
+			return nil, fmt.Errorf("invalid aspect ratio %q", v)
+		}
+
+		ratios = append(ratios, &aspectRatio{
+			Label: v,
+			Value: ratio,
+		})
+	}
+
+	return ratios, nil
+}
+
+func cropFile(file string, asset *visagoapi.Asset, ratios []*aspectRatio) []*cropResult {
+	results := []*cropResult{}
+
+	img, format, err := util.DecodeImageFile(file)
+	if err != nil {
+		for _, ratio := range ratios {
+			results = append(results, &cropResult{
+				File:        file,
+				AspectRatio: ratio.Label,
+				Error:       err.Error(),
+			})
+		}
+
+		return results
+	}
+
+	for _, ratio := range ratios {
+		result := &cropResult{
+			File:        file,
+			AspectRatio: ratio.Label,
+		}
+
+		rect, method := chooseCrop(asset, ratio.Value, img.Bounds())
+
+		result.Method = method
+		result.Rect = &cropRect{
+			X:      rect.Min.X,
+			Y:      rect.Min.Y,
+			Width:  rect.Dx(),
+			Height: rect.Dy(),
+		}
+
+		out, err := writeCrop(file, img, format, rect, ratio)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Output = out
+		}
+
+		results = append(results, result)
+	}
+
+	return results
+}
+
+// chooseCrop picks the crop rectangle for an aspect ratio. Crop hints
+// are preferred, followed by the area covering all faces and objects,
+// followed by the center of the image.
+func chooseCrop(asset *visagoapi.Asset, ratio float64, bounds image.Rectangle) (image.Rectangle, string) {
+	if asset != nil {
+		var best *visagoapi.PluginCropHintResult
+
+		for _, hint := range asset.CropHints {
+			if math.Abs(hint.AspectRatio-ratio) > aspectRatioTolerance {
+				continue
+			}
+
+			if best == nil || hint.Confidence > best.Confidence {
+				best = hint
+			}
+		}
+
+		if best != nil {
+			rect := best.BoundingPoly.Rectangle().Intersect(bounds)
+			if !rect.Empty() {
+				return fitAspectRatio(rect, ratio, bounds), cropMethodHints
+			}
+		}
+
+		faces := image.Rectangle{}
+		for _, face := range asset.Faces {
+			rect := face.BoundingPoly.Rectangle().Intersect(bounds)
+			if !rect.Empty() {
+				faces = faces.Union(rect)
+			}
+		}
+
+		objects := image.Rectangle{}
+		for _, object := range asset.Objects {
+			if object.BoundingPoly == nil {
+				continue
+			}
+
+			rect := object.BoundingPoly.Rectangle().Intersect(bounds)
+			if !rect.Empty() {
+				objects = objects.Union(rect)
+			}
+		}
+
+		switch {
+		case !faces.Empty() && !objects.Empty():
+			return fitAspectRatio(faces.Union(objects), ratio, bounds), cropMethodFacesObjects
+		case !faces.Empty():
+			return fitAspectRatio(faces, ratio, bounds), cropMethodFaces
+		case !objects.Empty():
+			return fitAspectRatio(objects, ratio, bounds), cropMethodObjects
+		}
+	}
+
+	return fitAspectRatio(bounds, ratio, bounds), cropMethodCenter
+}
+
+// fitAspectRatio grows focus to the requested aspect ratio, keeping
+// it centered where possible and within bounds.
+func fitAspectRatio(focus image.Rectangle, ratio float64, bounds image.Rectangle) image.Rectangle {
+	w, h := float64(focus.Dx()), float64(focus.Dy())
+
+	if w/h < ratio {
+		w = h * ratio
+	} else {
+		h = w / ratio
+	}
+
+	if w > float64(bounds.Dx()) {
+		w = float64(bounds.Dx())
+		h = w / ratio
+	}
+
+	if h > float64(bounds.Dy()) {
+		h = float64(bounds.Dy())
+		w = h * ratio
+	}
+
+	width := int(math.Max(1, math.Floor(w+0.5)))
+	height := int(math.Max(1, math.Floor(h+0.5)))
+
+	x := (focus.Min.X+focus.Max.X)/2 - width/2
+	y := (focus.Min.Y+focus.Max.Y)/2 - height/2
+
+	x = clamp(x, bounds.Min.X, bounds.Max.X-width)
+	y = clamp(y, bounds.Min.Y, bounds.Max.Y-height)
+
+	return image.Rect(x, y, x+width, y+height)
+}
+
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+
+	if v < min {
+		v = min
+	}
+
+	return v
+}
+
+func writeCrop(file string, img image.Image, format string, rect image.Rectangle, ratio *aspectRatio) (string, error) {
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return "", fmt.Errorf("unable to crop %s images", format)
+	}
+
+	cropped := sub.SubImage(rect)
+
+	dir := config.CropOutputDir
+	if dir == "" {
+		dir = filepath.Dir(file)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(file)
+	base := strings.TrimSuffix(filepath.Base(file), ext)
+	label := strings.Replace(ratio.Label, ":", "x", -1)
+	out := filepath.Join(dir, fmt.Sprintf("%s-crop-%s%s", base, label, ext))
+
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(f, cropped, &jpeg.Options{Quality: 95})
+	case "png":
+		err = png.Encode(f, cropped)
+	case "gif":
+		err = gif.Encode(f, cropped, nil)
+	default:
+		err = fmt.Errorf("unable to encode %s images", format)
+	}
+
+	if err != nil {
+		os.Remove(out)
+		return "", err
+	}
+
+	return out, nil
+}
+
+func displayCropResults(results []*cropResult) {
+	if config.JSONOutput {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			util.SmartPrint("error", fmt.Sprintf("%s\n", err), config.JSONOutput)
+			return
+		}
+
+		fmt.Printf("%s\n", b)
+		return
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			util.SmartPrint("error", fmt.Sprintf("%s [%s]: %s\n", r.File, r.AspectRatio, r.Error), false)
+			continue
+		}
+
+		fmt.Printf("%s [%s] %s %d,%d %dx%d -> %s\n",
+			r.File, r.AspectRatio, r.Method, r.Rect.X, r.Rect.Y, r.Rect.Width, r.Rect.Height, r.Output)
+	}
+}
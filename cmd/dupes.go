@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	defaultDupesDistance = 10
+	defaultDupesHash     = "phash"
+)
+
+// imageExtensions are the file types read when walking directories.
+var imageExtensions = map[string]bool{
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".png":  true,
+}
+
+// DupesCmd groups near-duplicate local images.
+var DupesCmd = &cobra.Command{
+	Use:   "dupes <files/dirs>",
+	Short: "Group near-duplicate images",
+	Long: `Group near-duplicate images using perceptual hashes from the phash plugin.
+Directories are searched recursively for JPEG, PNG and GIF files. Images are
+grouped when their hashes are within the maximum Hamming distance.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := dupesCommand(cmd, args)
+		if err != nil {
+			bail(err)
+		}
+	},
+}
+
+type dupeGroup struct {
+	Distance int      `json:"distance"`
+	Files    []string `json:"files"`
+}
+
+func prepareDupesFlags() {
+	if config.DupesDistance == 0 {
+		config.DupesDistance = defaultDupesDistance
+	}
+
+	if config.DupesHash == "" {
+		config.DupesHash = defaultDupesHash
+	}
+
+	DupesCmd.Flags().IntVarP(
+		&config.DupesDistance, "distance", "d", config.DupesDistance, "maximum Hamming distance between duplicates")
+	DupesCmd.Flags().StringVarP(
+		&config.DupesHash, "hash", "", config.DupesHash, "hash to compare (ahash, dhash or phash)")
+}
+
+func dupesCommand(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		help := cmd.HelpFunc()
+		help(cmd, args)
+
+		return nil
+	}
+
+	if config.DupesDistance < 0 || config.DupesDistance > 64 {
+		return fmt.Errorf("distance must be between 0 and 64")
+	}
+
+	switch config.DupesHash {
+	case "ahash", "dhash", "phash":
+	default:
+		return fmt.Errorf("unsupported hash %q", config.DupesHash)
+	}
+
+	files := findImages(args)
+	if len(files) == 0 {
+		util.SmartPrint("error", "failed to find any valid files\n", config.JSONOutput)
+		return nil
+	}
+
+	// Only the local phash plugin is run, duplicates are
+	// found before any paid provider sees the images.
+	client := visagoapi.NewClient(visagoapi.WithWhitelist("phash"))
+
+	pluginConfig := &visagoapi.PluginConfig{
+		Files:       files,
+		Verbose:     config.Verbose,
+		Features:    []string{visagoapi.HashesFeature},
+		Options:     config.Plugins,
+		Concurrency: config.Concurrency,
+	}
+
+	output, err := client.FetchResults(pluginConfig)
+	if err != nil {
+		return err
+	}
+
+	hashes := make(map[string]uint64)
+	if all, ok := output["all"]; ok {
+		if config.Verbose {
+			for _, e := range all.Errors {
+				util.SmartPrint("warn", fmt.Sprintf("%s\n", e), config.JSONOutput)
+			}
+		}
+
+		for _, asset := range all.Assets {
+			for _, h := range asset.Hashes {
+				hash, err := strconv.ParseUint(selectHash(h), 16, 64)
+				if err != nil {
+					continue
+				}
+
+				hashes[asset.Name] = hash
+				break
+			}
+		}
+	}
+
+	if len(hashes) == 0 {
+		util.SmartPrint("error", "failed to hash any files, is the phash plugin enabled?\n", config.JSONOutput)
+		return nil
+	}
+
+	displayDupeGroups(groupDupes(hashes, config.DupesDistance))
+
+	return nil
+}
+
+// findImages expands directories and drops files that are
+// not readable images, so one bad file does not fail the batch.
+func findImages(items []string) []string {
+	files := []string{}
+
+	for _, item := range items {
+		fi, err := os.Stat(item)
+		if err != nil {
+			util.SmartPrint("warn", fmt.Sprintf("%q is not a local file or directory\n", item), config.JSONOutput)
+			continue
+		}
+
+		if !fi.IsDir() {
+			if isImage(item) {
+				files = append(files, item)
+			} else {
+				util.SmartPrint("warn", fmt.Sprintf("%q is not a supported image\n", item), config.JSONOutput)
+			}
+
+			continue
+		}
+
+		filepath.Walk(item, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+
+			if imageExtensions[strings.ToLower(filepath.Ext(path))] && isImage(path) {
+				files = append(files, path)
+			}
+
+			return nil
+		})
+	}
+
+	return util.RemoveDuplicatesUnordered(files)
+}
+
+func isImage(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	_, _, err = image.DecodeConfig(f)
+
+	return err == nil
+}
+
+func selectHash(h *visagoapi.PluginHashResult) string {
+	switch config.DupesHash {
+	case "ahash":
+		return h.AHash
+	case "dhash":
+		return h.DHash
+	}
+
+	return h.PHash
+}
+
+// groupDupes joins files whose hashes are within maxDistance,
+// so a group holds every file reachable through close pairs.
+func groupDupes(hashes map[string]uint64, maxDistance int) []*dupeGroup {
+	files := []string{}
+	for f := range hashes {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+
+	parent := make([]int, len(files))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+
+		return parent[i]
+	}
+
+	for i := 0; i < len(files); i++ {
+		for j := i + 1; j < len(files); j++ {
+			if bits.OnesCount64(hashes[files[i]]^hashes[files[j]]) <= maxDistance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+	roots := []int{}
+
+	for i, f := range files {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+
+		members[root] = append(members[root], f)
+	}
+
+	groups := []*dupeGroup{}
+
+	for _, root := range roots {
+		group := members[root]
+		if len(group) < 2 {
+			continue
+		}
+
+		distance := 0
+		for i := 0; i < len(group); i++ {
+			for j := i + 1; j < len(group); j++ {
+				if d := bits.OnesCount64(hashes[group[i]] ^ hashes[group[j]]); d > distance {
+					distance = d
+				}
+			}
+		}
+
+		groups = append(groups, &dupeGroup{
+			Distance: distance,
+			Files:    group,
+		})
+	}
+
+	return groups
+}
+
+func displayDupeGroups(groups []*dupeGroup) {
+	if config.JSONOutput {
+		b, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			util.SmartPrint("error", fmt.Sprintf("%s\n", err), config.JSONOutput)
+			return
+		}
+
+		fmt.Printf("%s\n", b)
+		return
+	}
+
+	if len(groups) == 0 {
+		fmt.Printf("No duplicates found\n")
+		return
+	}
+
+	for i, g := range groups {
+		fmt.Printf("Group %d (distance %d):\n", i+1, g.Distance)
+
+		for _, f := range g.Files {
+			fmt.Printf("  %s\n", f)
+		}
+
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,380 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zquestz/visago/jpegmeta"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/xmp"
+
+	"github.com/spf13/cobra"
+)
+
+// backupExt is appended to the name of backed up files.
+const backupExt = ".bak"
+
+// EmbedCmd writes tags into the metadata of local JPEG files.
+var EmbedCmd = &cobra.Command{
+	Use:   "embed <files/dirs>",
+	Short: "Embed tags in JPEG metadata",
+	Long: `Embed the merged tags of JPEG files as IPTC keywords and XMP dc:subject
+keywords. Only the metadata segments are rewritten, the image data is copied
+as it is. Keywords written by other applications or already in the file
+are kept, only the keywords visago added are removed when a tag goes away.
+Files that fail in any plugin are skipped.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := embedCommand(cmd, args)
+		if err != nil {
+			bail(err)
+		}
+	},
+}
+
+type embedResult struct {
+	File     string   `json:"file"`
+	Output   string   `json:"output,omitempty"`
+	Backup   string   `json:"backup,omitempty"`
+	Keywords []string `json:"keywords"`
+	Changed  bool     `json:"changed"`
+	DryRun   bool     `json:"dry_run,omitempty"`
+	Skipped  string   `json:"skipped,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// ignoredEmbedFlags are the output flags that embed does not use.
+var ignoredEmbedFlags = []string{"output", "format", "template-file", "write-xmp", "wide", "top-tags"}
+
+func prepareEmbedFlags() {
+	EmbedCmd.Flags().StringVarP(
+		&config.EmbedOutputDir, "output-dir", "o", config.EmbedOutputDir, "directory for updated images instead of rewriting them")
+	EmbedCmd.Flags().BoolVarP(
+		&config.EmbedDryRun, "dry-run", "n", config.EmbedDryRun, "show the keywords without writing any file")
+	EmbedCmd.Flags().BoolVarP(
+		&config.EmbedBackup, "backup", "b", config.EmbedBackup, "keep a copy of rewritten images with a .bak extension")
+}
+
+func embedCommand(cmd *cobra.Command, args []string) error {
+	for _, name := range ignoredEmbedFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s cannot be used with embed", name)
+		}
+	}
+
+	if len(args) == 0 {
+		help := cmd.HelpFunc()
+		help(cmd, args)
+
+		return nil
+	}
+
+	files := []string{}
+	for _, file := range findImages(args) {
+		if !isJPEG(file) {
+			util.SmartPrint("warn", fmt.Sprintf("%q is not a JPEG image\n", file), config.JSONOutput)
+			continue
+		}
+
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		util.SmartPrint("error", "failed to find any valid files\n", config.JSONOutput)
+		return nil
+	}
+
+	if config.EmbedOutputDir != "" && !config.EmbedDryRun {
+		err := os.MkdirAll(config.EmbedOutputDir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	client := visagoapi.NewClient(
+		visagoapi.WithBlacklist(config.Blacklist...),
+		visagoapi.WithWhitelist(config.Whitelist...),
+	)
+
+	pluginConfig := &visagoapi.PluginConfig{
+		Files:       files,
+		Verbose:     config.Verbose,
+		TagScore:    config.TagScore,
+		Features:    []string{visagoapi.TagsFeature},
+		Options:     config.Plugins,
+		Concurrency: config.Concurrency,
+	}
+
+	output, failed := collectResults(client.Stream(context.Background(), pluginConfig))
+
+	assets := make(map[string]*visagoapi.Asset)
+	if all, ok := output["all"]; ok {
+		if config.Verbose {
+			for _, e := range all.Errors {
+				util.SmartPrint("warn", fmt.Sprintf("%s\n", e), config.JSONOutput)
+			}
+		}
+
+		for _, asset := range all.Assets {
+			assets[asset.Name] = asset
+		}
+	}
+
+	results := []*embedResult{}
+	outputs := make(map[string]string)
+
+	for _, file := range files {
+		result := &embedResult{File: file, DryRun: config.EmbedDryRun}
+		results = append(results, result)
+
+		// Without every result, the keywords of a failed plugin
+		// would be removed.
+		if e, ok := failed[file]; ok {
+			result.Skipped = e
+			continue
+		}
+
+		out := embedOutputPath(file)
+		if other, ok := outputs[out]; ok {
+			result.Error = fmt.Sprintf("%s is also written by %s", out, other)
+			continue
+		}
+		outputs[out] = file
+
+		err := embedFile(result, file, out, assets[file])
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+
+	displayEmbedResults(results)
+
+	return nil
+}
+
+// embedFile stores the tags of asset in file, writing the result to
+// out. Files without tags are only rewritten to remove the keywords
+// of a previous run, or copied as they are to a different out.
+func embedFile(result *embedResult, file, out string, asset *visagoapi.Asset) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	f, err := jpegmeta.Parse(b)
+	if err != nil {
+		return err
+	}
+
+	packet := xmp.New()
+	if x := f.XMP(); x != nil {
+		packet, err = xmp.Parse(x)
+		if err != nil {
+			return err
+		}
+	} else if asset == nil {
+		result.Keywords = f.Keywords()
+		return writeEmbedded(result, file, out, b, b)
+	}
+
+	if asset == nil {
+		asset = &visagoapi.Asset{Name: file}
+	}
+
+	root := config.XMPRoot
+	if root == "" {
+		root = defaultXMPRoot
+	}
+
+	iptc := f.Keywords()
+
+	previous := packet.AddedKeywords(root)
+	packet.SetKeywords(root, assetKeywords(asset), iptc...)
+
+	keywords := mergeKeywords(iptc, previous, packet.AddedKeywords(root), packet.Keywords())
+
+	err = f.SetXMP(packet.Bytes())
+	if err != nil {
+		return err
+	}
+
+	err = f.SetKeywords(keywords)
+	if err != nil {
+		return err
+	}
+
+	result.Keywords = keywords
+
+	return writeEmbedded(result, file, out, b, f.Bytes())
+}
+
+// writeEmbedded writes updated to out, unless it is file and nothing
+// changed. b is the original content of file.
+func writeEmbedded(result *embedResult, file, out string, b, updated []byte) error {
+	result.Changed = string(updated) != string(b)
+
+	if !result.Changed && out == file {
+		return nil
+	}
+
+	result.Output = out
+
+	if config.EmbedDryRun {
+		return nil
+	}
+
+	if config.EmbedBackup && out == file {
+		backup := file + backupExt
+
+		// An existing backup holds an older version of the file.
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			err = writeFileAtomic(backup, b, file)
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Backup = backup
+	}
+
+	return writeFileAtomic(out, updated, file)
+}
+
+// mergeKeywords returns the IPTC keywords with the subjects that
+// visago added before and no longer adds removed, and the current
+// XMP subjects added. Other IPTC keywords are always kept.
+func mergeKeywords(iptc, previous, added, subjects []string) []string {
+	current := make(map[string]bool)
+	for _, s := range added {
+		current[s] = true
+	}
+
+	removed := make(map[string]bool)
+	for _, s := range previous {
+		if !current[s] {
+			removed[s] = true
+			removed[jpegmeta.TruncateKeyword(s)] = true
+		}
+	}
+
+	keywords := []string{}
+	seen := make(map[string]bool)
+
+	for _, k := range iptc {
+		if removed[k] || seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		keywords = append(keywords, k)
+	}
+
+	for _, s := range subjects {
+		k := jpegmeta.TruncateKeyword(s)
+		if seen[k] {
+			continue
+		}
+
+		seen[k] = true
+		keywords = append(keywords, k)
+	}
+
+	return keywords
+}
+
+func embedOutputPath(file string) string {
+	if config.EmbedOutputDir == "" {
+		return file
+	}
+
+	return filepath.Join(config.EmbedOutputDir, filepath.Base(file))
+}
+
+// writeFileAtomic writes b to path through a temporary file in the
+// same directory, so readers never see a partial file. The file
+// gets the permissions of like.
+func writeFileAtomic(path string, b []byte, like string) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(like); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".visago-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
+
+func isJPEG(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	magic := make([]byte, 2)
+	if _, err := f.Read(magic); err != nil {
+		return false
+	}
+
+	return magic[0] == 0xff && magic[1] == 0xd8
+}
+
+func displayEmbedResults(results []*embedResult) {
+	if config.JSONOutput {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			util.SmartPrint("error", fmt.Sprintf("%s\n", err), config.JSONOutput)
+			return
+		}
+
+		fmt.Printf("%s\n", b)
+		return
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			util.SmartPrint("error", fmt.Sprintf("%s: %s\n", r.File, r.Error), false)
+			continue
+		}
+
+		if r.Skipped != "" {
+			util.SmartPrint("warn", fmt.Sprintf("%s skipped: %s\n", r.File, r.Skipped), false)
+			continue
+		}
+
+		status := "unchanged"
+		if r.Output != "" {
+			status = "-> " + r.Output
+			if r.DryRun {
+				status = "would write " + r.Output
+			}
+		}
+
+		fmt.Printf("%s %s: %s\n", r.File, status, strings.Join(r.Keywords, ", "))
+	}
+}
@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zquestz/visago/jpegmeta"
+	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/xmp"
+)
+
+const userPacket = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>beach</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+
+func TestMergeKeywords(t *testing.T) {
+	long := strings.Repeat("x", jpegmeta.MaxKeyword+10)
+
+	tests := []struct {
+		name                            string
+		iptc, previous, added, subjects []string
+		want                            []string
+	}{
+		{
+			name:     "user keywords",
+			iptc:     []string{"mountain", "family"},
+			subjects: []string{"lake", "mountain"},
+			added:    []string{"lake"},
+			want:     []string{"mountain", "family", "lake"},
+		},
+		{
+			name:     "removed tag",
+			iptc:     []string{"mountain", "family", "lake"},
+			previous: []string{"lake"},
+			subjects: []string{"mountain"},
+			want:     []string{"mountain", "family"},
+		},
+		{
+			name:     "kept tag",
+			iptc:     []string{"mountain", "lake"},
+			previous: []string{"lake"},
+			added:    []string{"lake"},
+			subjects: []string{"lake"},
+			want:     []string{"mountain", "lake"},
+		},
+		{
+			name:     "truncated tag",
+			iptc:     []string{"mountain", jpegmeta.TruncateKeyword(long)},
+			previous: []string{long},
+			want:     []string{"mountain"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := mergeKeywords(tt.iptc, tt.previous, tt.added, tt.subjects)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: keywords are %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func tagAsset(file string, names ...string) *visagoapi.Asset {
+	asset := &visagoapi.Asset{
+		Name: file,
+		Tags: make(map[string][]*visagoapi.PluginTagResult),
+	}
+
+	for _, name := range names {
+		asset.Tags[name] = []*visagoapi.PluginTagResult{{Name: name, Source: "test"}}
+	}
+
+	return asset
+}
+
+// TestEmbedFileKeepsUserKeywords embeds tags in a file that already
+// has IPTC and XMP keywords, then removes the tags again.
+func TestEmbedFileKeepsUserKeywords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "visago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := jpegmeta.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetXMP([]byte(userPacket)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetKeywords([]string{"mountain", "family"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "photo.jpg")
+	if err := ioutil.WriteFile(file, f.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		asset    *visagoapi.Asset
+		iptc     string
+		subjects string
+	}{
+		{tagAsset(file, "mountain", "lake"), "mountain,family,beach,lake", "beach,lake,mountain"},
+		{tagAsset(file, "lake"), "mountain,family,beach,lake", "beach,lake,mountain"},
+		{nil, "mountain,family,beach", "beach,mountain"},
+	}
+
+	for i, step := range steps {
+		result := &embedResult{File: file}
+		if err := embedFile(result, file, file, step.asset); err != nil {
+			t.Fatalf("run %d: %s", i+1, err)
+		}
+
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := jpegmeta.Parse(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.Join(f.Keywords(), ","); got != step.iptc {
+			t.Errorf("run %d: IPTC keywords are %s, want %s", i+1, got, step.iptc)
+		}
+
+		packet, err := xmp.Parse(f.XMP())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := strings.Join(packet.Keywords(), ","); got != step.subjects {
+			t.Errorf("run %d: XMP subjects are %s, want %s", i+1, got, step.subjects)
+		}
+	}
+}
+
+// TestEmbedFileCopiesToOutputDir embeds no tags in a file without
+// XMP, which is copied as it is to the output directory.
+func TestEmbedFileCopiesToOutputDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "visago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "photo.jpg")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out.jpg")
+	result := &embedResult{File: file}
+	if err := embedFile(result, file, out, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, buf.Bytes()) || result.Output != out || result.Changed {
+		t.Errorf("copy is %d bytes, want %d, result %+v", len(b), buf.Len(), result)
+	}
+}
+
+func TestCollectResultsFailed(t *testing.T) {
+	stream := make(chan *visagoapi.Event, 4)
+	stream <- &visagoapi.Event{Type: visagoapi.ErrorEvent, Plugin: "a", Items: []string{"x.jpg", "y.jpg"}, Error: "batch failed"}
+	stream <- &visagoapi.Event{Type: visagoapi.ErrorEvent, Plugin: "b", Items: []string{"y.jpg"}, Error: "y.jpg: bad file"}
+	stream <- &visagoapi.Event{Type: visagoapi.ItemEvent, Plugin: "b", Item: "z.jpg", Asset: tagAsset("z.jpg", "lake")}
+	stream <- &visagoapi.Event{Type: visagoapi.DoneEvent}
+	close(stream)
+
+	output, failed := collectResults(stream)
+
+	if len(failed) != 2 || failed["x.jpg"] != "batch failed" || failed["y.jpg"] != "batch failed" {
+		t.Errorf("failed items are %v", failed)
+	}
+
+	if all := output["all"]; all == nil || len(all.Assets) != 1 || len(all.Errors) != 2 {
+		t.Errorf("results are %+v", all)
+	}
+}
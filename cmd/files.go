@@ -1,14 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/zquestz/visago/util"
 	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/visagoapi/external"
+	"github.com/zquestz/visago/visagoapi/generic"
+	"github.com/zquestz/visago/visagoapi/goplugin"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/spf13/cobra"
@@ -22,11 +27,21 @@ const (
 // Stores configuration data.
 var config Config
 
+// Stores errors from loading Go plugin files.
+var pluginErrors []error
+
 // FilesCmd is the main command for Cobra.
 var FilesCmd = &cobra.Command{
 	Use:   "visago <files/urls>",
 	Short: "Visual AI Aggregator",
 	Long:  `Visual AI Aggregator`,
+	Args:  cobra.ArbitraryArgs,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		err := registerPlugins()
+		if err != nil {
+			bail(err)
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		err := filesCommand(cmd, args)
 		if err != nil {
@@ -42,6 +57,36 @@ func init() {
 	}
 
 	prepareFlags()
+	prepareCropFlags()
+	prepareDupesFlags()
+	prepareEmbedFlags()
+
+	FilesCmd.AddCommand(CropCmd)
+	FilesCmd.AddCommand(DupesCmd)
+	FilesCmd.AddCommand(EmbedCmd)
+}
+
+// registerPlugins adds the plugins defined in the configuration.
+// It runs after the built-in plugins have registered themselves.
+func registerPlugins() error {
+	for name, c := range config.Generic {
+		err := generic.Register(name, c)
+		if err != nil {
+			return fmt.Errorf("Failed to load generic plugin: %s", err)
+		}
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+
+	pluginDir := filepath.Join(dir, "plugins")
+
+	pluginErrors = goplugin.Register(pluginDir)
+	external.Register(pluginDir)
+
+	return nil
 }
 
 func bail(err error) {
@@ -52,20 +97,50 @@ func bail(err error) {
 func prepareFlags() {
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.DisplayVersion, "version", "", false, "display version")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Captions, "captions", "", false, "display captions")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Codes, "codes", "", false, "display barcodes and QR codes")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.Colors, "colors", "c", false, "display colors")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.Faces, "faces", "f", false, "display faces")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Hashes, "hashes", "", false, "display perceptual hashes")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Location, "location", "", false, "display location")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Metadata, "metadata", "m", false, "display file metadata")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Quality, "quality", "q", false, "display image quality")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.Tags, "tags", "t", false, "display tags")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Web, "web", "w", false, "display web detection")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.Verbose, "verbose", "v", config.Verbose, "verbose mode")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.ListPlugins, "list-plugins", "l", false, "list supported plugins")
 	FilesCmd.PersistentFlags().BoolVarP(
 		&config.JSONOutput, "json", "j", false, "provide JSON output")
+	FilesCmd.PersistentFlags().StringVarP(
+		&config.Output, "output", "", config.Output, "output format (text, json, ndjson, csv or tsv)")
+	FilesCmd.PersistentFlags().StringVarP(
+		&config.Format, "format", "", config.Format, "Go template rendered for each asset")
+	FilesCmd.PersistentFlags().StringVarP(
+		&config.TemplateFile, "template-file", "", config.TemplateFile, "Go template file rendered with all the results")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.WriteXMP, "write-xmp", "", config.WriteXMP, "write tags and faces to XMP sidecar files")
+	FilesCmd.PersistentFlags().StringVarP(
+		&config.XMPRoot, "xmp-root", "", config.XMPRoot, "root of the hierarchical XMP keywords")
+	FilesCmd.PersistentFlags().BoolVarP(
+		&config.Wide, "wide", "", config.Wide, "one CSV row per asset with the top tags as columns")
+	FilesCmd.PersistentFlags().IntVarP(
+		&config.TopTags, "top-tags", "", config.TopTags, "number of tag columns in the wide CSV format")
 	FilesCmd.PersistentFlags().Float64VarP(
 		&config.TagScore, "tag-score", "s", 0, "minimum tag score")
+	FilesCmd.PersistentFlags().IntVarP(
+		&config.Concurrency, "concurrency", "", config.Concurrency, "number of plugin batches run at the same time")
 }
 
 // Where all the work happens.
@@ -75,11 +150,27 @@ func filesCommand(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	err := resolveOutput()
+	if err != nil {
+		return err
+	}
+
 	visagoapi.SetBlacklist(config.Blacklist)
 	visagoapi.SetWhitelist(config.Whitelist)
 
+	if config.Verbose && !config.ListPlugins {
+		for _, err := range pluginErrors {
+			util.SmartPrint("warn", fmt.Sprintf("Failed to load plugin %s\n", err), config.JSONOutput)
+		}
+	}
+
 	if config.ListPlugins {
-		fmt.Printf(visagoapi.DisplayPlugins())
+		fmt.Print(visagoapi.DisplayPlugins())
+
+		for _, err := range pluginErrors {
+			util.SmartPrint("warn", fmt.Sprintf("Failed to load plugin %s\n", err), config.JSONOutput)
+		}
+
 		return nil
 	}
 
@@ -116,6 +207,14 @@ func filesCommand(cmd *cobra.Command, args []string) error {
 		}
 
 		features := []string{}
+		if config.Captions {
+			features = append(features, visagoapi.CaptionsFeature)
+		}
+
+		if config.Codes {
+			features = append(features, visagoapi.CodesFeature)
+		}
+
 		if config.Colors {
 			features = append(features, visagoapi.ColorsFeature)
 		}
@@ -124,24 +223,70 @@ func filesCommand(cmd *cobra.Command, args []string) error {
 			features = append(features, visagoapi.FacesFeature)
 		}
 
+		if config.Hashes {
+			features = append(features, visagoapi.HashesFeature)
+		}
+
+		if config.Location {
+			features = append(features, visagoapi.LocationFeature)
+		}
+
+		if config.Metadata {
+			features = append(features, visagoapi.MetadataFeature)
+		}
+
+		if config.Quality {
+			features = append(features, visagoapi.QualityFeature)
+		}
+
 		if config.Tags {
 			features = append(features, visagoapi.TagsFeature)
 		}
 
+		if config.Web {
+			features = append(features, visagoapi.WebFeature)
+		}
+
 		pluginConfig := &visagoapi.PluginConfig{
-			URLs:     urls,
-			Files:    files,
-			Verbose:  config.Verbose,
-			TagScore: config.TagScore,
-			Features: features,
+			URLs:        urls,
+			Files:       files,
+			Verbose:     config.Verbose,
+			TagScore:    config.TagScore,
+			Features:    features,
+			MaxResults:  map[string]map[string]int64{"googlevision": config.GoogleVision.MaxResults.Map()},
+			Options:     config.Plugins,
+			Concurrency: config.Concurrency,
+		}
+
+		if config.Output == outputNDJSON {
+			if config.WriteXMP {
+				return fmt.Errorf("--write-xmp cannot be used with --output ndjson")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			return visagoapi.WriteNDJSON(os.Stdout, visagoapi.Stream(ctx, pluginConfig))
 		}
 
-		output, err := visagoapi.RunPlugins(pluginConfig, config.JSONOutput)
+		// Templates are parsed before any plugin runs.
+		r, err := renderer()
 		if err != nil {
 			return err
 		}
 
-		fmt.Printf(output)
+		results, failed := collectResults(visagoapi.Stream(context.Background(), pluginConfig))
+
+		if config.WriteXMP {
+			writeSidecars(pluginConfig, results, failed)
+		}
+
+		output, err := r.Render(results)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(output)
 
 		return nil
 	}
@@ -172,3 +317,26 @@ func sortItems(items []string) (urls []string, files []string, errs []error) {
 
 	return
 }
+
+// collectResults reads every event of a run and returns the results,
+// with the first error of each item that failed in any plugin.
+func collectResults(stream <-chan *visagoapi.Event) (map[string]*visagoapi.Result, map[string]string) {
+	events := []*visagoapi.Event{}
+	failed := make(map[string]string)
+
+	for e := range stream {
+		events = append(events, e)
+
+		if e.Type != visagoapi.ErrorEvent {
+			continue
+		}
+
+		for _, item := range e.Items {
+			if _, ok := failed[item]; !ok {
+				failed[item] = e.Error
+			}
+		}
+	}
+
+	return visagoapi.CollectResults(events), failed
+}
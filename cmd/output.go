@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// Output formats supported by --output.
+const (
+	outputText     = "text"
+	outputJSON     = "json"
+	outputNDJSON   = "ndjson"
+	outputCSV      = "csv"
+	outputTSV      = "tsv"
+	outputTemplate = "template"
+)
+
+// resolveOutput validates the output format. The --json flag
+// selects JSON when no format is set, and --format or
+// --template-file select a template.
+func resolveOutput() error {
+	if config.Format != "" && config.TemplateFile != "" {
+		return fmt.Errorf("--format and --template-file cannot be used together")
+	}
+
+	if config.Format != "" || config.TemplateFile != "" {
+		if config.Output != "" && config.Output != outputTemplate {
+			return fmt.Errorf("templates cannot be used with --output %s", config.Output)
+		}
+
+		config.Output = outputTemplate
+	}
+
+	if config.Output == "" {
+		config.Output = outputText
+		if config.JSONOutput {
+			config.Output = outputJSON
+		}
+	}
+
+	switch config.Output {
+	case outputText, outputCSV, outputTSV:
+	case outputTemplate:
+		if config.Format == "" && config.TemplateFile == "" {
+			return fmt.Errorf("--output template requires --format or --template-file")
+		}
+	case outputJSON, outputNDJSON:
+		config.JSONOutput = true
+	default:
+		return fmt.Errorf("unsupported output format %q", config.Output)
+	}
+
+	return nil
+}
+
+// renderer returns the renderer of the buffered output formats.
+func renderer() (visagoapi.Renderer, error) {
+	switch config.Output {
+	case outputJSON:
+		return visagoapi.JSONRenderer, nil
+	case outputCSV, outputTSV:
+		o := visagoapi.CSVOptions{
+			Wide:    config.Wide,
+			TopTags: config.TopTags,
+		}
+
+		if config.Output == outputTSV {
+			o.Comma = '\t'
+		}
+
+		return visagoapi.CSVRenderer(o), nil
+	case outputTemplate:
+		if config.TemplateFile != "" {
+			b, err := ioutil.ReadFile(config.TemplateFile)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to read template: %s", err)
+			}
+
+			tmpl, err := visagoapi.NewTemplate(config.TemplateFile, string(b))
+			if err != nil {
+				return nil, fmt.Errorf("Failed to parse template: %s", err)
+			}
+
+			return visagoapi.TemplateRenderer(tmpl), nil
+		}
+
+		tmpl, err := visagoapi.NewTemplate("format", config.Format)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse template: %s", err)
+		}
+
+		return visagoapi.AssetTemplateRenderer(tmpl), nil
+	}
+
+	return visagoapi.TextRenderer, nil
+}
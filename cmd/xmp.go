@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"sort"
+
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/xmp"
+)
+
+// defaultXMPRoot is the root of the hierarchical keywords.
+const defaultXMPRoot = "visago"
+
+// writeSidecars writes the merged tags and faces of each local file
+// to its XMP sidecar. Only the requested features are updated, and
+// no sidecar is created for files without results. Files that failed
+// in any plugin are skipped, so the keywords of the failed plugin
+// are not removed.
+func writeSidecars(pluginConfig *visagoapi.PluginConfig, output map[string]*visagoapi.Result, failed map[string]string) {
+	assets := make(map[string]*visagoapi.Asset)
+	if all, ok := output["all"]; ok {
+		for _, asset := range all.Assets {
+			assets[asset.Name] = asset
+		}
+	}
+
+	for _, file := range pluginConfig.Files {
+		if e, ok := failed[file]; ok {
+			util.SmartPrint("warn", fmt.Sprintf("Skipped %s: %s\n", xmp.SidecarPath(file), e), config.JSONOutput)
+			continue
+		}
+
+		err := writeSidecar(pluginConfig, file, assets[file])
+		if err != nil {
+			util.SmartPrint("warn", fmt.Sprintf("Failed to write %s: %s\n", xmp.SidecarPath(file), err), config.JSONOutput)
+		}
+	}
+}
+
+func writeSidecar(pluginConfig *visagoapi.PluginConfig, file string, asset *visagoapi.Asset) error {
+	if asset == nil {
+		if _, err := os.Stat(xmp.SidecarPath(file)); os.IsNotExist(err) {
+			return nil
+		}
+
+		asset = &visagoapi.Asset{Name: file}
+	}
+
+	packet, err := xmp.ReadSidecar(file)
+	if err != nil {
+		return err
+	}
+
+	err = updatePacket(packet, pluginConfig, file, asset)
+	if err != nil {
+		return err
+	}
+
+	err = xmp.WriteSidecar(file, packet)
+	if err != nil {
+		return err
+	}
+
+	if config.Verbose {
+		util.SmartPrint("info", fmt.Sprintf("Wrote %s\n", xmp.SidecarPath(file)), config.JSONOutput)
+	}
+
+	return nil
+}
+
+// updatePacket stores the tags and faces of asset in packet.
+func updatePacket(packet *xmp.Packet, pluginConfig *visagoapi.PluginConfig, file string, asset *visagoapi.Asset) error {
+	root := config.XMPRoot
+	if root == "" {
+		root = defaultXMPRoot
+	}
+
+	if pluginConfig.EnabledFeature(visagoapi.TagsFeature) {
+		packet.SetKeywords(root, assetKeywords(asset))
+	}
+
+	if pluginConfig.EnabledFeature(visagoapi.FacesFeature) {
+		width, height, err := imageSize(file)
+		if err != nil {
+			return err
+		}
+
+		regions := []xmp.Region{}
+		for _, f := range asset.Faces {
+			regions = append(regions, xmp.Region{
+				Rect:   f.BoundingPoly.Rectangle(),
+				Source: f.Source,
+			})
+		}
+
+		packet.SetFaceRegions(root, width, height, regions)
+	}
+
+	return nil
+}
+
+// assetKeywords returns the merged tags of asset sorted by name.
+func assetKeywords(asset *visagoapi.Asset) []xmp.Keyword {
+	names := []string{}
+	for name := range asset.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keywords := []xmp.Keyword{}
+	for _, name := range names {
+		for _, t := range asset.Tags[name] {
+			keywords = append(keywords, xmp.Keyword{
+				Name:   t.Name,
+				Source: t.Source,
+			})
+		}
+	}
+
+	return keywords
+}
+
+func imageSize(file string) (int, int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return c.Width, c.Height, nil
+}
@@ -6,9 +6,19 @@ import (
 
 	"github.com/zquestz/visago/visagoapi"
 
+	_ "github.com/zquestz/visago/visagoapi/azurevision"
+	_ "github.com/zquestz/visago/visagoapi/barcode"
 	_ "github.com/zquestz/visago/visagoapi/clarifai"
+	_ "github.com/zquestz/visago/visagoapi/geocode"
 	_ "github.com/zquestz/visago/visagoapi/googlevision"
 	_ "github.com/zquestz/visago/visagoapi/imagga"
+	_ "github.com/zquestz/visago/visagoapi/llmvision"
+	_ "github.com/zquestz/visago/visagoapi/localcolor"
+	_ "github.com/zquestz/visago/visagoapi/localfaces"
+	_ "github.com/zquestz/visago/visagoapi/metadata"
+	_ "github.com/zquestz/visago/visagoapi/phash"
+	_ "github.com/zquestz/visago/visagoapi/quality"
+	_ "github.com/zquestz/visago/visagoapi/rekognition"
 )
 
 // Simple app that processes URLs from the command line.
@@ -21,7 +31,7 @@ func main() {
 	pluginConfig := &visagoapi.PluginConfig{
 		URLs: os.Args[1:],
 		// To only enable select features, set them below.
-		// By default all features are enabled.
+		// By default captions, colors, faces, metadata and tags are enabled.
 		// Features: []string{visagoapi.TagsFeature, visagoapi.ColorsFeature, visagoapi.FacesFeature},
 	}
 
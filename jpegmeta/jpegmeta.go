@@ -0,0 +1,527 @@
+// Package jpegmeta reads and writes the XMP packet and the IPTC
+// keywords embedded in JPEG files without decoding the image.
+//
+// Only the APP1 XMP segment and the APP13 Photoshop segment are
+// rewritten. Every other segment and the entropy coded image data
+// are copied byte for byte, so embedding metadata never recompresses
+// the image.
+package jpegmeta
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	markerSOI   = 0xd8
+	markerSOS   = 0xda
+	markerEOI   = 0xd9
+	markerAPP0  = 0xe0
+	markerAPP1  = 0xe1
+	markerAPP13 = 0xed
+
+	// maxSegment is the largest payload of a segment.
+	maxSegment = 0xffff - 2
+
+	// MaxKeyword is the longest IPTC keyword in bytes.
+	MaxKeyword = 64
+)
+
+var (
+	xmpHeader       = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	exifHeader      = []byte("Exif\x00\x00")
+	photoshopHeader = []byte("Photoshop 3.0\x00")
+	resourceType    = []byte("8BIM")
+
+	// utf8Charset is the ISO 2022 escape sequence of UTF-8,
+	// stored in the IPTC coded character set dataset.
+	utf8Charset = []byte("\x1b%G")
+)
+
+// Photoshop image resources holding IPTC data and its digest.
+const (
+	resourceIPTC       = 0x0404
+	resourceIPTCDigest = 0x0425
+)
+
+// IPTC datasets, as record and dataset numbers.
+var (
+	datasetCharset  = [2]byte{1, 90}
+	datasetVersion  = [2]byte{2, 0}
+	datasetKeywords = [2]byte{2, 25}
+)
+
+type segment struct {
+	marker byte
+	data   []byte
+}
+
+// File is a JPEG file split into the segments before the image
+// data and the image data itself.
+type File struct {
+	segments []*segment
+	image    []byte
+}
+
+// Parse splits the JPEG file in b into its segments.
+func Parse(b []byte) (*File, error) {
+	if len(b) < 2 || b[0] != 0xff || b[1] != markerSOI {
+		return nil, fmt.Errorf("not a JPEG file")
+	}
+
+	f := &File{}
+
+	i := 2
+	for {
+		if i >= len(b) || b[i] != 0xff {
+			return nil, fmt.Errorf("invalid JPEG marker at offset %d", i)
+		}
+
+		// Markers may be preceded by fill bytes.
+		for i < len(b) && b[i] == 0xff {
+			i++
+		}
+
+		if i >= len(b) {
+			return nil, fmt.Errorf("unexpected end of JPEG file")
+		}
+
+		marker := b[i]
+		i++
+
+		switch {
+		case marker == markerSOS:
+			f.image = b[i-2:]
+			return f, nil
+		case marker == markerEOI:
+			return nil, fmt.Errorf("no image data in JPEG file")
+		case standalone(marker):
+			f.segments = append(f.segments, &segment{marker: marker})
+			continue
+		}
+
+		if i+2 > len(b) {
+			return nil, fmt.Errorf("unexpected end of JPEG file")
+		}
+
+		length := int(binary.BigEndian.Uint16(b[i:]))
+		if length < 2 || i+length > len(b) {
+			return nil, fmt.Errorf("invalid JPEG segment length at offset %d", i)
+		}
+
+		f.segments = append(f.segments, &segment{
+			marker: marker,
+			data:   b[i+2 : i+length],
+		})
+
+		i += length
+	}
+}
+
+// Bytes returns the JPEG file.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+
+	buf.Write([]byte{0xff, markerSOI})
+
+	for _, s := range f.segments {
+		buf.Write([]byte{0xff, s.marker})
+		if standalone(s.marker) {
+			continue
+		}
+
+		binary.Write(&buf, binary.BigEndian, uint16(len(s.data)+2))
+		buf.Write(s.data)
+	}
+
+	buf.Write(f.image)
+
+	return buf.Bytes()
+}
+
+// standalone reports whether marker has no segment data.
+func standalone(marker byte) bool {
+	return marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7)
+}
+
+// ImageData returns the file from the start of scan marker to
+// the end, which no method of File modifies.
+func (f *File) ImageData() []byte {
+	return f.image
+}
+
+// XMP returns the XMP packet of the file, or nil when there is none.
+func (f *File) XMP() []byte {
+	if s := f.find(markerAPP1, xmpHeader); s != nil {
+		return s.data[len(xmpHeader):]
+	}
+
+	return nil
+}
+
+// SetXMP replaces the XMP packet of the file. Packets that do
+// not fit in a single segment are rejected.
+func (f *File) SetXMP(packet []byte) error {
+	if len(xmpHeader)+len(packet) > maxSegment {
+		return fmt.Errorf("XMP packet of %d bytes is too large to embed", len(packet))
+	}
+
+	data := append(append([]byte{}, xmpHeader...), packet...)
+
+	if s := f.find(markerAPP1, xmpHeader); s != nil {
+		s.data = data
+		return nil
+	}
+
+	f.insert(&segment{marker: markerAPP1, data: data})
+
+	return nil
+}
+
+// Keywords returns the IPTC keywords of the file.
+func (f *File) Keywords() []string {
+	keywords := []string{}
+
+	s := f.find(markerAPP13, photoshopHeader)
+	if s == nil {
+		return keywords
+	}
+
+	resources, err := parseResources(s.data[len(photoshopHeader):])
+	if err != nil {
+		return keywords
+	}
+
+	for _, r := range resources {
+		if r.id != resourceIPTC {
+			continue
+		}
+
+		datasets, err := parseDatasets(r.data)
+		if err != nil {
+			return keywords
+		}
+
+		for _, d := range datasets {
+			if d.tag == datasetKeywords {
+				keywords = append(keywords, string(d.data))
+			}
+		}
+	}
+
+	return keywords
+}
+
+// SetKeywords replaces the IPTC keywords of the file. Keywords
+// longer than MaxKeyword bytes are truncated, and the other IPTC
+// datasets and Photoshop resources are kept.
+func (f *File) SetKeywords(keywords []string) error {
+	s := f.find(markerAPP13, photoshopHeader)
+
+	resources := []*resource{}
+	if s != nil {
+		var err error
+		resources, err = parseResources(s.data[len(photoshopHeader):])
+		if err != nil {
+			return err
+		}
+	}
+
+	var iptc *resource
+	for _, r := range resources {
+		if r.id == resourceIPTC {
+			iptc = r
+			break
+		}
+	}
+
+	if iptc == nil {
+		if len(keywords) == 0 {
+			return nil
+		}
+
+		iptc = &resource{id: resourceIPTC, name: []byte{0, 0}}
+		resources = append(resources, iptc)
+	}
+
+	datasets, err := parseDatasets(iptc.data)
+	if err != nil {
+		return err
+	}
+
+	iptc.data = formatDatasets(setKeywords(datasets, keywords))
+
+	for _, r := range resources {
+		if r.id == resourceIPTCDigest {
+			sum := md5.Sum(iptc.data)
+			r.data = sum[:]
+		}
+	}
+
+	data := append(append([]byte{}, photoshopHeader...), formatResources(resources)...)
+	if len(data) > maxSegment {
+		return fmt.Errorf("IPTC data of %d bytes is too large to embed", len(data))
+	}
+
+	if s != nil {
+		s.data = data
+		return nil
+	}
+
+	f.insert(&segment{marker: markerAPP13, data: data})
+
+	return nil
+}
+
+// find returns the first segment with marker whose data
+// starts with header.
+func (f *File) find(marker byte, header []byte) *segment {
+	for _, s := range f.segments {
+		if s.marker == marker && bytes.HasPrefix(s.data, header) {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// insert adds s after the JFIF and Exif segments, which
+// readers expect to come first.
+func (f *File) insert(s *segment) {
+	i := 0
+	for i < len(f.segments) {
+		c := f.segments[i]
+		if c.marker != markerAPP0 && !(c.marker == markerAPP1 && bytes.HasPrefix(c.data, exifHeader)) {
+			break
+		}
+
+		i++
+	}
+
+	f.segments = append(f.segments, nil)
+	copy(f.segments[i+1:], f.segments[i:])
+	f.segments[i] = s
+}
+
+// resource is a Photoshop image resource block.
+type resource struct {
+	id   uint16
+	name []byte
+	data []byte
+}
+
+func parseResources(b []byte) ([]*resource, error) {
+	resources := []*resource{}
+
+	for len(b) > 0 {
+		if len(b) < 7 || !bytes.HasPrefix(b, resourceType) {
+			return nil, fmt.Errorf("invalid Photoshop image resource")
+		}
+
+		r := &resource{id: binary.BigEndian.Uint16(b[4:])}
+
+		// The name is a Pascal string padded to an even length.
+		n := 1 + int(b[6])
+		n += n % 2
+		if 6+n+4 > len(b) {
+			return nil, fmt.Errorf("invalid Photoshop image resource")
+		}
+
+		r.name = b[6 : 6+n]
+		b = b[6+n:]
+
+		size := int(binary.BigEndian.Uint32(b))
+		if size < 0 || 4+size > len(b) {
+			return nil, fmt.Errorf("invalid Photoshop image resource size")
+		}
+
+		r.data = b[4 : 4+size]
+		b = b[4+size:]
+
+		// The data is padded to an even length.
+		if size%2 == 1 && len(b) > 0 {
+			b = b[1:]
+		}
+
+		resources = append(resources, r)
+	}
+
+	return resources, nil
+}
+
+func formatResources(resources []*resource) []byte {
+	var buf bytes.Buffer
+
+	for _, r := range resources {
+		buf.Write(resourceType)
+		binary.Write(&buf, binary.BigEndian, r.id)
+		buf.Write(r.name)
+		binary.Write(&buf, binary.BigEndian, uint32(len(r.data)))
+		buf.Write(r.data)
+
+		if len(r.data)%2 == 1 {
+			buf.WriteByte(0)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// dataset is an IPTC dataset, tagged by record and dataset number.
+type dataset struct {
+	tag  [2]byte
+	data []byte
+}
+
+func parseDatasets(b []byte) ([]*dataset, error) {
+	datasets := []*dataset{}
+
+	for len(b) > 0 {
+		// Some writers pad the IPTC data with zeros.
+		if b[0] == 0 {
+			break
+		}
+
+		if len(b) < 5 || b[0] != 0x1c {
+			return nil, fmt.Errorf("invalid IPTC dataset")
+		}
+
+		d := &dataset{tag: [2]byte{b[1], b[2]}}
+
+		size := int(binary.BigEndian.Uint16(b[3:]))
+		b = b[5:]
+
+		// Extended datasets store the size of the size.
+		if size&0x8000 != 0 {
+			n := size & 0x7fff
+			if n > 4 || n > len(b) {
+				return nil, fmt.Errorf("invalid IPTC dataset size")
+			}
+
+			size = 0
+			for _, c := range b[:n] {
+				size = size<<8 | int(c)
+			}
+
+			b = b[n:]
+		}
+
+		if size > len(b) {
+			return nil, fmt.Errorf("invalid IPTC dataset size")
+		}
+
+		d.data = b[:size]
+		b = b[size:]
+
+		datasets = append(datasets, d)
+	}
+
+	return datasets, nil
+}
+
+func formatDatasets(datasets []*dataset) []byte {
+	var buf bytes.Buffer
+
+	for _, d := range datasets {
+		buf.Write([]byte{0x1c, d.tag[0], d.tag[1]})
+
+		if len(d.data) < 0x8000 {
+			binary.Write(&buf, binary.BigEndian, uint16(len(d.data)))
+		} else {
+			buf.Write([]byte{0x80, 0x04})
+			binary.Write(&buf, binary.BigEndian, uint32(len(d.data)))
+		}
+
+		buf.Write(d.data)
+	}
+
+	return buf.Bytes()
+}
+
+// setKeywords replaces the keyword datasets with keywords. The
+// datasets are kept in record and dataset order, adding the record
+// version and declaring UTF-8 when they are needed.
+func setKeywords(datasets []*dataset, keywords []string) []*dataset {
+	kept := []*dataset{}
+	hasCharset := false
+	hasVersion := false
+
+	for _, d := range datasets {
+		switch d.tag {
+		case datasetKeywords:
+			continue
+		case datasetCharset:
+			hasCharset = true
+		case datasetVersion:
+			hasVersion = true
+		}
+
+		kept = append(kept, d)
+	}
+
+	added := []*dataset{}
+
+	if !hasCharset && !isASCII(keywords) {
+		added = append(added, &dataset{tag: datasetCharset, data: utf8Charset})
+	}
+
+	if !hasVersion && len(keywords) > 0 {
+		added = append(added, &dataset{tag: datasetVersion, data: []byte{0, 4}})
+	}
+
+	for _, k := range keywords {
+		added = append(added, &dataset{tag: datasetKeywords, data: []byte(TruncateKeyword(k))})
+	}
+
+	for _, d := range added {
+		i := 0
+		for i < len(kept) && !after(kept[i].tag, d.tag) {
+			i++
+		}
+
+		kept = append(kept, nil)
+		copy(kept[i+1:], kept[i:])
+		kept[i] = d
+	}
+
+	return kept
+}
+
+// after reports whether tag a sorts after tag b.
+func after(a, b [2]byte) bool {
+	if a[0] != b[0] {
+		return a[0] > b[0]
+	}
+
+	return a[1] > b[1]
+}
+
+func isASCII(values []string) bool {
+	for _, v := range values {
+		for i := 0; i < len(v); i++ {
+			if v[i] >= utf8.RuneSelf {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// TruncateKeyword shortens s to MaxKeyword bytes without
+// splitting a character.
+func TruncateKeyword(s string) string {
+	n := MaxKeyword
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
@@ -0,0 +1,101 @@
+package jpegmeta
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"strings"
+	"testing"
+)
+
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 16), uint8(y * 16), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	b := testJPEG(t)
+
+	f, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f.XMP() != nil || len(f.Keywords()) != 0 {
+		t.Fatalf("new file has XMP %q and keywords %q", f.XMP(), f.Keywords())
+	}
+
+	long := strings.Repeat("é", 40)
+	packet := []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"></x:xmpmeta>`)
+
+	if err := f.SetXMP(packet); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.SetKeywords([]string{"mountain", "lac d'été", long}); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := Parse(f.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(g.XMP(), packet) {
+		t.Errorf("XMP is %q, want %q", g.XMP(), packet)
+	}
+
+	want := []string{"mountain", "lac d'été", TruncateKeyword(long)}
+	if strings.Join(g.Keywords(), ",") != strings.Join(want, ",") {
+		t.Errorf("keywords are %q, want %q", g.Keywords(), want)
+	}
+
+	if len(TruncateKeyword(long)) > MaxKeyword {
+		t.Errorf("truncated keyword has %d bytes", len(TruncateKeyword(long)))
+	}
+
+	orig, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(g.ImageData(), orig.ImageData()) {
+		t.Error("image data changed")
+	}
+
+	// Rewriting the same metadata leaves the file as it is.
+	if err := g.SetKeywords(g.Keywords()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(g.Bytes(), f.Bytes()) {
+		t.Error("same keywords changed the file")
+	}
+
+	if err := g.SetKeywords([]string{"mountain"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := Parse(g.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(h.Keywords(), ",") != "mountain" {
+		t.Errorf("keywords are %q, want %q", h.Keywords(), []string{"mountain"})
+	}
+}
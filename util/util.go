@@ -3,7 +3,14 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"image"
+	"os"
 	"strings"
+
+	// Register decoders used by DecodeImageFile and DecodeImageConfig.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 )
 
 // SmartPrint formats messages as text or JSON with a given severity.
@@ -49,3 +56,27 @@ func RemoveDuplicatesUnordered(elements []string) []string {
 
 	return result
 }
+
+// DecodeImageFile decodes a JPEG, PNG or GIF file and
+// returns the image along with the format name.
+func DecodeImageFile(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	return image.Decode(f)
+}
+
+// DecodeImageConfig returns the dimensions of a JPEG, PNG or
+// GIF file along with the format name, without decoding it.
+func DecodeImageConfig(path string) (image.Config, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	defer f.Close()
+
+	return image.DecodeConfig(f)
+}
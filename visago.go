@@ -4,9 +4,19 @@ import (
 	"fmt"
 	"os"
 
+	_ "github.com/zquestz/visago/visagoapi/azurevision"
+	_ "github.com/zquestz/visago/visagoapi/barcode"
 	_ "github.com/zquestz/visago/visagoapi/clarifai"
+	_ "github.com/zquestz/visago/visagoapi/geocode"
 	_ "github.com/zquestz/visago/visagoapi/googlevision"
 	_ "github.com/zquestz/visago/visagoapi/imagga"
+	_ "github.com/zquestz/visago/visagoapi/llmvision"
+	_ "github.com/zquestz/visago/visagoapi/localcolor"
+	_ "github.com/zquestz/visago/visagoapi/localfaces"
+	_ "github.com/zquestz/visago/visagoapi/metadata"
+	_ "github.com/zquestz/visago/visagoapi/phash"
+	_ "github.com/zquestz/visago/visagoapi/quality"
+	_ "github.com/zquestz/visago/visagoapi/rekognition"
 
 	"github.com/zquestz/visago/cmd"
 )
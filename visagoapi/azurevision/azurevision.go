@@ -0,0 +1,341 @@
+package azurevision
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+const analyzePath = "/vision/v3.2/analyze"
+
+// colorNames maps the color names returned by Azure to hex values.
+var colorNames = map[string]string{
+	"Black":  "#000000",
+	"Blue":   "#0000ff",
+	"Brown":  "#a52a2a",
+	"Gray":   "#808080",
+	"Grey":   "#808080",
+	"Green":  "#008000",
+	"Orange": "#ffa500",
+	"Pink":   "#ffc0cb",
+	"Purple": "#800080",
+	"Red":    "#ff0000",
+	"Teal":   "#008080",
+	"White":  "#ffffff",
+	"Yellow": "#ffff00",
+}
+
+func init() {
+	visagoapi.AddPluginFactory("azurevision", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and stores
+// configuration data needed by Azure Computer Vision.
+type Plugin struct {
+	configured bool
+	endpoint   string
+	key        string
+	options    map[string]string
+	responses  map[string]map[string]*response
+}
+
+// Configure stores the settings from the plugins section
+// of the configuration. Supported keys are endpoint and key.
+func (p *Plugin) Configure(options map[string]string) {
+	p.options = options
+}
+
+// Perform gathers metadata from Azure Computer Vision.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	if len(c.URLs) == 0 && len(c.Files) == 0 {
+		return "", nil, fmt.Errorf("must supply files/URLs")
+	}
+
+	visualFeatures := []string{}
+
+	if c.EnabledFeature(visagoapi.TagsFeature) {
+		visualFeatures = append(visualFeatures, "Tags")
+	}
+
+	if c.EnabledFeature(visagoapi.ColorsFeature) {
+		visualFeatures = append(visualFeatures, "Color")
+	}
+
+	if c.EnabledFeature(visagoapi.FacesFeature) {
+		visualFeatures = append(visualFeatures, "Faces")
+	}
+
+	requestID := nuid.Next()
+	p.responses[requestID] = make(map[string]*response)
+
+	if len(visualFeatures) == 0 {
+		return requestID, p, nil
+	}
+
+	client := &http.Client{}
+	analyzeURL := fmt.Sprintf("%s%s?visualFeatures=%s",
+		strings.TrimSuffix(p.endpoint, "/"), analyzePath, url.QueryEscape(strings.Join(visualFeatures, ",")))
+
+	for _, uri := range c.URLs {
+		b, err := json.Marshal(map[string]string{"url": uri})
+		if err != nil {
+			return "", nil, err
+		}
+
+		resp, err := p.analyze(client, analyzeURL, "application/json", bytes.NewReader(b))
+		if err != nil {
+			return "", nil, err
+		}
+
+		p.responses[requestID][uri] = resp
+	}
+
+	for _, file := range c.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", nil, err
+		}
+
+		resp, err := p.analyze(client, analyzeURL, "application/octet-stream", f)
+		f.Close()
+		if err != nil {
+			return "", nil, err
+		}
+
+		p.responses[requestID][file] = resp
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	if p.responses[requestID] == nil {
+		return tags, fmt.Errorf("tag request has not been made to azure")
+	}
+
+	for k, resp := range p.responses[requestID] {
+		tags[k] = make(map[string]*visagoapi.PluginTagResult)
+
+		for _, t := range resp.Tags {
+			if t.Confidence > score {
+				tag := &visagoapi.PluginTagResult{
+					Name:  t.Name,
+					Score: t.Confidence,
+				}
+
+				tags[k][t.Name] = tag
+			}
+		}
+	}
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	if p.responses[requestID] == nil {
+		return colors, fmt.Errorf("color request has not been made to azure")
+	}
+
+	for k, resp := range p.responses[requestID] {
+		if resp.Color == nil {
+			continue
+		}
+
+		colors[k] = make(map[string]*visagoapi.PluginColorResult)
+
+		for _, name := range resp.Color.DominantColors {
+			hex, ok := colorNames[name]
+			if !ok {
+				continue
+			}
+
+			color, err := colorResult(name, hex)
+			if err != nil {
+				return colors, err
+			}
+
+			colors[k][color.Hex] = color
+		}
+
+		if resp.Color.AccentColor != "" {
+			color, err := colorResult("", "#"+strings.ToLower(resp.Color.AccentColor))
+			if err != nil {
+				return colors, err
+			}
+
+			colors[k][color.Hex] = color
+		}
+	}
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	if p.responses[requestID] == nil {
+		return faces, fmt.Errorf("face request has not been made to azure")
+	}
+
+	for k, resp := range p.responses[requestID] {
+		for _, f := range resp.Faces {
+			if f.FaceRectangle == nil {
+				continue
+			}
+
+			r := f.FaceRectangle
+
+			face := &visagoapi.PluginFaceResult{
+				BoundingPoly: &visagoapi.BoundingPoly{
+					Vertices: []*visagoapi.Vertex{
+						{X: r.Left, Y: r.Top},
+						{X: r.Left + r.Width, Y: r.Top},
+						{X: r.Left + r.Width, Y: r.Top + r.Height},
+						{X: r.Left, Y: r.Top + r.Height},
+					},
+				},
+			}
+
+			faces[k] = append(faces[k], face)
+		}
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.responses = make(map[string]map[string]*response)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.responses {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// BatchLimits sends one item per batch. The v3.2 analyze
+// operation takes one image per request, either a URL or up
+// to 4MB of image bytes.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{MaxItems: 1}
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	endpoint := p.options["endpoint"]
+	if endpoint == "" {
+		endpoint = os.Getenv("AZURE_VISION_ENDPOINT")
+	}
+
+	key := p.options["key"]
+	if key == "" {
+		key = os.Getenv("AZURE_VISION_KEY")
+	}
+
+	if endpoint == "" || key == "" {
+		p.configured = false
+		return fmt.Errorf("credentials not found")
+	}
+
+	p.responses = make(map[string]map[string]*response)
+
+	p.endpoint = endpoint
+	p.key = key
+	p.configured = true
+
+	return nil
+}
+
+func (p *Plugin) analyze(client *http.Client, analyzeURL string, contentType string, body io.Reader) (*response, error) {
+	req, err := http.NewRequest("POST", analyzeURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Ocp-Apim-Subscription-Key", p.key)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := errorResponse{}
+		if json.Unmarshal(respBody, &errResp) == nil {
+			if errResp.Error != nil && errResp.Error.Message != "" {
+				return nil, fmt.Errorf("azure analyze failed: %s", errResp.Error.Message)
+			}
+
+			if errResp.Message != "" {
+				return nil, fmt.Errorf("azure analyze failed: %s", errResp.Message)
+			}
+		}
+
+		return nil, fmt.Errorf("azure analyze failed: %s", resp.Status)
+	}
+
+	aResp := response{}
+	err = json.Unmarshal(respBody, &aResp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &aResp, nil
+}
+
+func colorResult(name, hex string) (*visagoapi.PluginColorResult, error) {
+	cf, err := colorful.Hex(hex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &visagoapi.PluginColorResult{
+		Name:  name,
+		Hex:   hex,
+		Alpha: 1,
+		Red:   float64(int(cf.R * 255)),
+		Green: float64(int(cf.G * 255)),
+		Blue:  float64(int(cf.B * 255)),
+	}, nil
+}
@@ -0,0 +1,42 @@
+package azurevision
+
+type response struct {
+	Tags  []*tag  `json:"tags"`
+	Color *color  `json:"color"`
+	Faces []*face `json:"faces"`
+}
+
+type tag struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+type color struct {
+	DominantColorForeground string   `json:"dominantColorForeground"`
+	DominantColorBackground string   `json:"dominantColorBackground"`
+	DominantColors          []string `json:"dominantColors"`
+	AccentColor             string   `json:"accentColor"`
+	IsBWImg                 bool     `json:"isBwImg"`
+}
+
+type face struct {
+	FaceRectangle *faceRectangle `json:"faceRectangle"`
+}
+
+type faceRectangle struct {
+	Left   int64 `json:"left"`
+	Top    int64 `json:"top"`
+	Width  int64 `json:"width"`
+	Height int64 `json:"height"`
+}
+
+type errorResponse struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Error   *errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,223 @@
+package barcode
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/oned"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func init() {
+	visagoapi.AddPluginFactory("barcode", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and decodes QR codes
+// and common 1D barcodes in local files without any network
+// requests. URLs are skipped.
+type Plugin struct {
+	configured bool
+	codes      map[string]map[string][]*visagoapi.PluginCodeResult
+	errors     map[string]map[string]error
+}
+
+// Perform decodes each file and runs the barcode readers.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.codes[requestID] = make(map[string][]*visagoapi.PluginCodeResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.CodesFeature) {
+		return requestID, p, nil
+	}
+
+	for _, file := range c.Files {
+		codes, err := decode(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		if len(codes) > 0 {
+			p.codes[requestID][file] = codes
+		}
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Codes returns the barcodes and QR codes on an entry
+func (p *Plugin) Codes(requestID string) (codes map[string][]*visagoapi.PluginCodeResult, err error) {
+	codes = make(map[string][]*visagoapi.PluginCodeResult)
+
+	if p.codes[requestID] == nil {
+		return codes, fmt.Errorf("code request has not been made to barcode")
+	}
+
+	for k, c := range p.codes[requestID] {
+		codes[k] = c
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to barcode")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.codes = make(map[string]map[string][]*visagoapi.PluginCodeResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.codes {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	p.codes = make(map[string]map[string][]*visagoapi.PluginCodeResult)
+	p.errors = make(map[string]map[string]error)
+	p.configured = true
+
+	return nil
+}
+
+// decode runs every reader over the file. Each reader
+// reports at most one code, so an image with two codes
+// of the same symbology only returns the first.
+func decode(file string) ([]*visagoapi.PluginCodeResult, error) {
+	img, _, err := util.DecodeImageFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return nil, err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER: true,
+	}
+
+	readers := []gozxing.Reader{
+		qrcode.NewQRCodeReader(),
+		oned.NewMultiFormatUPCEANReader(hints),
+		oned.NewCode128Reader(),
+		oned.NewCode39Reader(),
+	}
+
+	codes := []*visagoapi.PluginCodeResult{}
+
+	for _, reader := range readers {
+		// Readers return an error when no code is found.
+		result, err := reader.Decode(bmp, hints)
+		if err != nil || result == nil {
+			continue
+		}
+
+		codes = append(codes, &visagoapi.PluginCodeResult{
+			Symbology:    result.GetBarcodeFormat().String(),
+			Payload:      result.GetText(),
+			BoundingPoly: boundingPoly(result.GetResultPoints()),
+		})
+	}
+
+	return codes, nil
+}
+
+// boundingPoly returns the box around the points reported by
+// the decoder. For 1D barcodes these lie on the scan line, so
+// the box has no height.
+func boundingPoly(points []gozxing.ResultPoint) *visagoapi.BoundingPoly {
+	if len(points) == 0 {
+		return nil
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	for _, pt := range points {
+		if pt == nil {
+			continue
+		}
+
+		minX = math.Min(minX, pt.GetX())
+		minY = math.Min(minY, pt.GetY())
+		maxX = math.Max(maxX, pt.GetX())
+		maxY = math.Max(maxY, pt.GetY())
+	}
+
+	if math.IsInf(minX, 1) {
+		return nil
+	}
+
+	x0, y0 := int64(math.Floor(minX)), int64(math.Floor(minY))
+	x1, y1 := int64(math.Ceil(maxX)), int64(math.Ceil(maxY))
+
+	return &visagoapi.BoundingPoly{
+		Vertices: []*visagoapi.Vertex{
+			{X: x0, Y: y0},
+			{X: x1, Y: y0},
+			{X: x1, Y: y1},
+			{X: x0, Y: y1},
+		},
+	}
+}
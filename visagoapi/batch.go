@@ -0,0 +1,89 @@
+package visagoapi
+
+import "os"
+
+// DefaultConcurrency is the number of batches run at the
+// same time when PluginConfig.Concurrency is not set.
+const DefaultConcurrency = 8
+
+// batch is the part of the input sent in one Perform() call.
+type batch struct {
+	URLs  []string
+	Files []string
+}
+
+// batchLimits returns the limits declared by the plugins
+// the factory creates.
+func batchLimits(factory PluginFactory) BatchLimits {
+	p := factory()
+
+	if s, ok := p.(*sharedPlugin); ok {
+		p = s.Plugin
+	}
+
+	if b, ok := p.(BatchingPlugin); ok {
+		return b.BatchLimits()
+	}
+
+	return BatchLimits{}
+}
+
+// splitBatches splits the URLs and files of pluginConfig into
+// batches within limits, keeping the input order. URLs do not
+// count towards MaxBytes.
+func splitBatches(pluginConfig *PluginConfig, limits BatchLimits) []*batch {
+	batches := []*batch{}
+
+	current := &batch{}
+	size := int64(0)
+
+	full := func(fileSize int64) bool {
+		items := len(current.URLs) + len(current.Files)
+		if items == 0 {
+			return false
+		}
+
+		if limits.MaxItems > 0 && items >= limits.MaxItems {
+			return true
+		}
+
+		return limits.MaxBytes > 0 && size+fileSize > limits.MaxBytes
+	}
+
+	for _, u := range pluginConfig.URLs {
+		if full(0) {
+			batches = append(batches, current)
+			current = &batch{}
+			size = 0
+		}
+
+		current.URLs = append(current.URLs, u)
+	}
+
+	for _, f := range pluginConfig.Files {
+		fileSize := int64(0)
+		if fi, err := os.Stat(f); err == nil {
+			fileSize = fi.Size()
+		}
+
+		if full(fileSize) {
+			batches = append(batches, current)
+			current = &batch{}
+			size = 0
+		}
+
+		current.Files = append(current.Files, f)
+		size += fileSize
+	}
+
+	return append(batches, current)
+}
+
+// config returns a copy of pluginConfig limited to the batch.
+func (b *batch) config(pluginConfig *PluginConfig) *PluginConfig {
+	pc := *pluginConfig
+	pc.URLs = b.URLs
+	pc.Files = b.Files
+
+	return &pc
+}
@@ -11,7 +11,9 @@ import (
 )
 
 func init() {
-	visagoapi.AddPlugin("clarifai", &Plugin{})
+	visagoapi.AddPluginFactory("clarifai", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
 }
 
 // Plugin implements the Plugin interface and stores
@@ -209,6 +211,15 @@ func (p *Plugin) RequestIDs() ([]string, error) {
 	return keys, nil
 }
 
+// BatchLimits returns the largest batch accepted by Clarifai.
+// Large uploads are split to keep requests from timing out.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{
+		MaxItems: 128,
+		MaxBytes: 20 << 20,
+	}
+}
+
 // Setup sets up the plugin for use. This should only
 // be called once per plugin.
 func (p *Plugin) Setup() error {
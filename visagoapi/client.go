@@ -0,0 +1,226 @@
+package visagoapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Client runs a set of plugins. Each client has its own plugin
+// selection, so clients with different plugins can be used in
+// the same process. The package level functions use a default
+// client over the plugins registered with AddPlugin.
+type Client struct {
+	mu              sync.RWMutex
+	plugins         map[string]PluginFactory
+	whitelist       map[string]bool
+	blacklist       map[string]bool
+	defaultFeatures []string
+	renderer        Renderer
+}
+
+// defaultClient backs the package level functions.
+var defaultClient = NewClient()
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithPlugins sets the plugins of the client, keyed by name.
+// Without it the client uses the registered plugins. Each
+// instance is shared by every run of the client, so runs of
+// it are serialized.
+func WithPlugins(plugins map[string]Plugin) Option {
+	return func(c *Client) {
+		if c.plugins == nil {
+			c.plugins = make(map[string]PluginFactory)
+		}
+
+		for name, p := range plugins {
+			c.plugins[name] = sharedFactory(p)
+		}
+	}
+}
+
+// WithPluginFactories sets the plugins of the client, keyed by
+// name. Every run creates its own instances from the factories.
+func WithPluginFactories(factories map[string]PluginFactory) Option {
+	return func(c *Client) {
+		if c.plugins == nil {
+			c.plugins = make(map[string]PluginFactory)
+		}
+
+		for name, f := range factories {
+			c.plugins[name] = f
+		}
+	}
+}
+
+// WithWhitelist only runs the named plugins.
+func WithWhitelist(names ...string) Option {
+	return func(c *Client) {
+		c.whitelist = nameSet(names)
+	}
+}
+
+// WithBlacklist never runs the named plugins.
+func WithBlacklist(names ...string) Option {
+	return func(c *Client) {
+		c.blacklist = nameSet(names)
+	}
+}
+
+// WithDefaultFeatures sets the features used when a
+// PluginConfig does not list any.
+func WithDefaultFeatures(features ...string) Option {
+	return func(c *Client) {
+		c.defaultFeatures = append([]string{}, features...)
+	}
+}
+
+// WithRenderer sets the renderer used by Run.
+func WithRenderer(r Renderer) Option {
+	return func(c *Client) {
+		c.renderer = r
+	}
+}
+
+// NewClient returns a client configured with options. By default
+// it uses the registered plugins and renders text.
+func NewClient(options ...Option) *Client {
+	c := &Client{
+		whitelist: make(map[string]bool),
+		blacklist: make(map[string]bool),
+		renderer:  TextRenderer,
+	}
+
+	for _, option := range options {
+		option(c)
+	}
+
+	return c
+}
+
+// AddPlugin adds a shared plugin instance to the client.
+// Clients without explicit plugins add it to the registered
+// plugins.
+func (c *Client) AddPlugin(name string, plugin Plugin) {
+	c.AddPluginFactory(name, sharedFactory(plugin))
+}
+
+// AddPluginFactory adds a plugin factory to the client.
+// Clients without explicit plugins add it to the registered
+// plugins.
+func (c *Client) AddPluginFactory(name string, factory PluginFactory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.registry()[name] = factory
+}
+
+// SetBlacklist replaces the plugins the client never runs.
+// An empty list clears the blacklist.
+func (c *Client) SetBlacklist(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.blacklist = nameSet(names)
+}
+
+// SetWhitelist replaces the plugins the client only runs.
+// An empty list clears the whitelist.
+func (c *Client) SetWhitelist(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.whitelist = nameSet(names)
+}
+
+// PluginNames returns a sorted slice of plugin names
+// applying both the whitelist and then the blacklist.
+func (c *Client) PluginNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := []string{}
+
+	for key := range c.registry() {
+		if len(c.whitelist) > 0 && !c.whitelist[key] {
+			continue
+		}
+
+		if c.blacklist[key] {
+			continue
+		}
+
+		names = append(names, key)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// DisplayPlugins displays the plugins the client runs.
+func (c *Client) DisplayPlugins() string {
+	names := c.PluginNames()
+
+	return fmt.Sprintf("%s\n", strings.Join(names, "\n"))
+}
+
+// Run runs the plugins and formats the results with the
+// client renderer.
+func (c *Client) Run(pluginConfig *PluginConfig) (string, error) {
+	output, err := c.FetchResults(pluginConfig)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	renderer := c.renderer
+	c.mu.RUnlock()
+
+	return renderer.Render(output)
+}
+
+// factory returns the factory of the named plugin.
+func (c *Client) factory(name string) PluginFactory {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.registry()[name]
+}
+
+// registry returns the plugins of the client. The lock
+// must be held by the caller.
+func (c *Client) registry() map[string]PluginFactory {
+	if c.plugins == nil {
+		return Plugins
+	}
+
+	return c.plugins
+}
+
+// withDefaults returns pluginConfig with the client default
+// features applied when it does not list any.
+func (c *Client) withDefaults(pluginConfig *PluginConfig) *PluginConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(pluginConfig.Features) > 0 || len(c.defaultFeatures) == 0 {
+		return pluginConfig
+	}
+
+	pc := *pluginConfig
+	pc.Features = append([]string{}, c.defaultFeatures...)
+
+	return &pc
+}
+
+func nameSet(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
@@ -0,0 +1,161 @@
+package visagoapi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// echoPlugin tags every URL with its path, so each result shows
+// which call the item came from.
+type echoPlugin struct {
+	requests int
+	tags     map[string]map[string]map[string]*PluginTagResult
+}
+
+func (p *echoPlugin) Setup() error {
+	if p.tags == nil {
+		p.tags = make(map[string]map[string]map[string]*PluginTagResult)
+	}
+
+	return nil
+}
+
+func (p *echoPlugin) Perform(c *PluginConfig) (string, PluginResult, error) {
+	p.requests++
+	requestID := fmt.Sprintf("%d", p.requests)
+
+	p.tags[requestID] = make(map[string]map[string]*PluginTagResult)
+	for _, u := range c.URLs {
+		name := echoTag(u)
+		p.tags[requestID][u] = map[string]*PluginTagResult{
+			name: {Name: name, Score: 1},
+		}
+	}
+
+	return requestID, p, nil
+}
+
+func (p *echoPlugin) Reset() {
+	p.tags = make(map[string]map[string]map[string]*PluginTagResult)
+}
+
+func (p *echoPlugin) RequestIDs() ([]string, error) {
+	keys := []string{}
+	for k := range p.tags {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (p *echoPlugin) Tags(requestID string, score float64) (map[string]map[string]*PluginTagResult, error) {
+	if p.tags[requestID] == nil {
+		return nil, fmt.Errorf("unknown request %s", requestID)
+	}
+
+	return p.tags[requestID], nil
+}
+
+func (p *echoPlugin) Faces(requestID string) (map[string][]*PluginFaceResult, error) {
+	return make(map[string][]*PluginFaceResult), nil
+}
+
+func (p *echoPlugin) Colors(requestID string) (map[string]map[string]*PluginColorResult, error) {
+	return make(map[string]map[string]*PluginColorResult), nil
+}
+
+func echoTag(u string) string {
+	return strings.Replace(strings.TrimPrefix(u, "https://example.com/"), "/", "-", -1)
+}
+
+// TestFetchResultsConcurrent runs a client from several goroutines
+// while plugins are added to it. Run it with -race.
+func TestFetchResultsConcurrent(t *testing.T) {
+	const (
+		callers = 16
+		items   = 8
+	)
+
+	c := NewClient(WithPluginFactories(map[string]PluginFactory{
+		"factory": func() Plugin { return &echoPlugin{} },
+	}))
+	c.AddPlugin("shared", &echoPlugin{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+
+	for i := 0; i < callers; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			c.AddPlugin(fmt.Sprintf("extra%d", i), &echoPlugin{})
+		}(i)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs <- fetchOwnResults(c, i, items)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// fetchOwnResults fetches the tags of the items of caller and
+// checks that no item of another caller shows up.
+func fetchOwnResults(c *Client, caller, items int) error {
+	urls := []string{}
+	for j := 0; j < items; j++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%d/%d.jpg", caller, j))
+	}
+
+	output, err := c.FetchResults(&PluginConfig{
+		URLs:        urls,
+		Features:    []string{TagsFeature},
+		Concurrency: 2,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{"factory", "shared", allKey} {
+		result, ok := output[name]
+		if !ok {
+			return fmt.Errorf("caller %d: no %s results", caller, name)
+		}
+
+		if len(result.Errors) > 0 {
+			return fmt.Errorf("caller %d: %s errors: %v", caller, name, result.Errors)
+		}
+
+		if len(result.Assets) != items {
+			return fmt.Errorf("caller %d: %s has %d assets, want %d", caller, name, len(result.Assets), items)
+		}
+
+		seen := make(map[string]bool)
+		for _, a := range result.Assets {
+			if !strings.HasPrefix(a.Name, fmt.Sprintf("https://example.com/%d/", caller)) || seen[a.Name] {
+				return fmt.Errorf("caller %d: unexpected %s asset %s", caller, name, a.Name)
+			}
+			seen[a.Name] = true
+
+			want := echoTag(a.Name)
+			if len(a.Tags) != 1 || a.Tags[want] == nil {
+				return fmt.Errorf("caller %d: %s tags of %s are %v, want %s", caller, name, a.Name, a.Tags, want)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package visagoapi
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// DefaultTopTags is the number of tag columns in the wide
+// CSV format when CSVOptions.TopTags is not set.
+const DefaultTopTags = 5
+
+// CSVOptions configure the CSV renderer.
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' when not set.
+	// Use '\t' for TSV.
+	Comma rune
+
+	// Wide writes one row per merged asset with its top tags
+	// as columns, instead of one row per tag, color and face.
+	Wide bool
+
+	// TopTags is the number of tag columns in the wide format.
+	TopTags int
+}
+
+// CSVRenderer returns a renderer writing the results as CSV.
+//
+// The long format has one row per asset, plugin and tag, color
+// or face, identified by the kind column. Errors are written as
+// error rows. The wide format has one row per merged asset.
+func CSVRenderer(o CSVOptions) Renderer {
+	return RendererFunc(func(output map[string]*Result) (string, error) {
+		var buf bytes.Buffer
+
+		w := csv.NewWriter(&buf)
+		if o.Comma != 0 {
+			w.Comma = o.Comma
+		}
+
+		var err error
+		if o.Wide {
+			err = writeWideCSV(w, output, o.TopTags)
+		} else {
+			err = writeLongCSV(w, output)
+		}
+		if err != nil {
+			return "", err
+		}
+
+		w.Flush()
+
+		return buf.String(), w.Error()
+	})
+}
+
+func writeLongCSV(w *csv.Writer, output map[string]*Result) error {
+	err := w.Write([]string{"kind", "asset", "source", "name", "score", "hex", "pixel_fraction", "x", "y", "width", "height"})
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for k := range output {
+		if k != allKey {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, asset := range output[name].Assets {
+			for _, t := range asset.TopTags(0) {
+				err = w.Write([]string{"tag", asset.Name, name, t.Name, formatFloat(t.Score), "", "", "", "", "", ""})
+				if err != nil {
+					return err
+				}
+			}
+
+			hexes := []string{}
+			for hex := range asset.Colors {
+				hexes = append(hexes, hex)
+			}
+			sort.Strings(hexes)
+
+			for _, hex := range hexes {
+				for _, c := range asset.Colors[hex] {
+					err = w.Write([]string{"color", asset.Name, name, c.Name, formatFloat(c.Score), c.Hex, formatFloat(c.PixelFraction), "", "", "", ""})
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, f := range asset.Faces {
+				r := f.BoundingPoly.Rectangle()
+
+				err = w.Write([]string{"face", asset.Name, name, "", formatFloat(f.DetectionScore), "", "",
+					strconv.Itoa(r.Min.X), strconv.Itoa(r.Min.Y), strconv.Itoa(r.Dx()), strconv.Itoa(r.Dy())})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, e := range output[name].Errors {
+			err = w.Write([]string{"error", "", name, e, "", "", "", "", "", "", ""})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeWideCSV(w *csv.Writer, output map[string]*Result, topTags int) error {
+	if topTags <= 0 {
+		topTags = DefaultTopTags
+	}
+
+	header := []string{"asset"}
+	for i := 1; i <= topTags; i++ {
+		header = append(header, fmt.Sprintf("tag_%d", i), fmt.Sprintf("tag_%d_score", i))
+	}
+
+	err := w.Write(header)
+	if err != nil {
+		return err
+	}
+
+	all := output[allKey]
+	if all == nil {
+		return nil
+	}
+
+	assets := append([]*Asset{}, all.Assets...)
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+
+	for _, asset := range assets {
+		row := []string{asset.Name}
+
+		tags := asset.TopTags(topTags)
+		for i := 0; i < topTags; i++ {
+			if i < len(tags) {
+				row = append(row, tags[i].Name, formatFloat(tags[i].Score))
+			} else {
+				row = append(row, "", "")
+			}
+		}
+
+		err = w.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,309 @@
+package external
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// Prefix is the file name prefix of external plugin executables.
+const Prefix = "visago-plugin-"
+
+// response is the JSON document an external plugin writes to stdout.
+type response struct {
+	Assets []*asset `json:"assets"`
+	Error  string   `json:"error"`
+}
+
+type asset struct {
+	Name   string                         `json:"name"`
+	Tags   []*visagoapi.PluginTagResult   `json:"tags"`
+	Colors []*visagoapi.PluginColorResult `json:"colors"`
+	Faces  []*visagoapi.PluginFaceResult  `json:"faces"`
+}
+
+// Register discovers external plugins in dirs followed by the
+// directories in $PATH and adds them to visagoapi.Plugins. The
+// plugin name is the executable name without the prefix. The
+// first plugin found for a name wins, and existing plugins are
+// never replaced.
+func Register(dirs ...string) {
+	for name, path := range Discover(dirs...) {
+		name, path := name, path
+
+		if _, ok := visagoapi.Plugins[name]; ok {
+			continue
+		}
+
+		visagoapi.AddPluginFactory(name, func() visagoapi.Plugin {
+			return &Plugin{
+				name: name,
+				path: path,
+			}
+		})
+	}
+}
+
+// Discover returns the external plugin executables found
+// in dirs and $PATH, keyed by plugin name.
+func Discover(dirs ...string) map[string]string {
+	plugins := make(map[string]string)
+
+	searchDirs := []string{}
+	searchDirs = append(searchDirs, dirs...)
+	searchDirs = append(searchDirs, filepath.SplitList(os.Getenv("PATH"))...)
+
+	for _, dir := range searchDirs {
+		if dir == "" {
+			continue
+		}
+
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		names := []string{}
+		for _, fi := range files {
+			names = append(names, fi.Name())
+		}
+		sort.Strings(names)
+
+		for _, fileName := range names {
+			path := filepath.Join(dir, fileName)
+
+			name, ok := pluginName(path)
+			if !ok {
+				continue
+			}
+
+			if _, ok := plugins[name]; !ok {
+				plugins[name] = path
+			}
+		}
+	}
+
+	return plugins
+}
+
+func pluginName(path string) (string, bool) {
+	base := filepath.Base(path)
+	if !strings.HasPrefix(base, Prefix) {
+		return "", false
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() {
+		return "", false
+	}
+
+	if runtime.GOOS == "windows" {
+		if !strings.EqualFold(filepath.Ext(base), ".exe") {
+			return "", false
+		}
+
+		base = base[:len(base)-len(filepath.Ext(base))]
+	} else if fi.Mode()&0111 == 0 {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(base, Prefix)
+	if name == "" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// Plugin implements the Plugin interface by running an
+// executable that speaks JSON over stdin and stdout.
+type Plugin struct {
+	name       string
+	path       string
+	configured bool
+	responses  map[string]*response
+}
+
+// Perform sends the PluginConfig to the executable as JSON
+// on stdin and reads the results from stdout.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	if len(c.URLs) == 0 && len(c.Files) == 0 {
+		return "", nil, fmt.Errorf("must supply files/URLs")
+	}
+
+	// Only pass along the options meant for this plugin.
+	pc := *c
+	pc.Options = nil
+	if options, ok := c.Options[p.name]; ok {
+		pc.Options = map[string]map[string]string{p.name: options}
+	}
+
+	input, err := json.Marshal(&pc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", nil, fmt.Errorf("%s failed: %s", filepath.Base(p.path), msg)
+		}
+
+		return "", nil, fmt.Errorf("%s failed: %s", filepath.Base(p.path), err)
+	}
+
+	resp := response{}
+	err = json.Unmarshal(stdout.Bytes(), &resp)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s returned invalid JSON: %s", filepath.Base(p.path), err)
+	}
+
+	if resp.Error != "" {
+		return "", nil, fmt.Errorf("%s", resp.Error)
+	}
+
+	requestID := nuid.Next()
+	p.responses[requestID] = &resp
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	if p.responses[requestID] == nil {
+		return tags, fmt.Errorf("tag request has not been made to %s", p.name)
+	}
+
+	for _, a := range p.responses[requestID].Assets {
+		if a == nil || len(a.Tags) == 0 {
+			continue
+		}
+
+		tags[a.Name] = make(map[string]*visagoapi.PluginTagResult)
+
+		for _, t := range a.Tags {
+			if t != nil && t.Name != "" && t.Score > score {
+				tag := &visagoapi.PluginTagResult{
+					Name:  t.Name,
+					Score: t.Score,
+				}
+
+				tags[a.Name][t.Name] = tag
+			}
+		}
+	}
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	if p.responses[requestID] == nil {
+		return colors, fmt.Errorf("color request has not been made to %s", p.name)
+	}
+
+	for _, a := range p.responses[requestID].Assets {
+		if a == nil || len(a.Colors) == 0 {
+			continue
+		}
+
+		colors[a.Name] = make(map[string]*visagoapi.PluginColorResult)
+
+		for _, c := range a.Colors {
+			if c == nil || c.Hex == "" {
+				continue
+			}
+
+			c.Source = ""
+			colors[a.Name][c.Hex] = c
+		}
+	}
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	if p.responses[requestID] == nil {
+		return faces, fmt.Errorf("face request has not been made to %s", p.name)
+	}
+
+	for _, a := range p.responses[requestID].Assets {
+		if a == nil {
+			continue
+		}
+
+		for _, f := range a.Faces {
+			if f == nil {
+				continue
+			}
+
+			f.Source = ""
+			faces[a.Name] = append(faces[a.Name], f)
+		}
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.responses = make(map[string]*response)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.responses {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	_, err := os.Stat(p.path)
+	if err != nil {
+		p.configured = false
+		return fmt.Errorf("executable not found: %s", err)
+	}
+
+	p.responses = make(map[string]*response)
+	p.configured = true
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package external
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+const nullResponse = `{"assets":[null,{"name":"x","tags":[null,{"name":"lake","score":0.9}],"colors":[null],"faces":[null]}]}`
+
+// TestNullEntries runs a plugin that returns null assets and
+// results, which are skipped.
+func TestNullEntries(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script plugin")
+	}
+
+	dir, err := ioutil.TempDir("", "visago")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, Prefix+"null")
+	script := "#!/bin/sh\ncat >/dev/null\necho '" + nullResponse + "'\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Plugin{name: "null", path: path}
+	if err := p.Setup(); err != nil {
+		t.Fatal(err)
+	}
+
+	requestID, _, err := p.Perform(&visagoapi.PluginConfig{URLs: []string{"x"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := p.Tags(requestID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags) != 1 || len(tags["x"]) != 1 || tags["x"]["lake"] == nil {
+		t.Errorf("tags are %v, want lake for x", tags)
+	}
+
+	colors, err := p.Colors(requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(colors["x"]) != 0 {
+		t.Errorf("colors are %v, want none", colors)
+	}
+
+	faces, err := p.Faces(requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(faces) != 0 {
+		t.Errorf("faces are %v, want none", faces)
+	}
+}
@@ -0,0 +1,561 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// Supported request body types.
+const (
+	BodyMultipart  = "multipart"
+	BodyRaw        = "raw"
+	BodyBase64JSON = "base64_json"
+)
+
+const (
+	defaultBodyTemplate   = `{"image": "{{.Base64}}"}`
+	defaultMultipartField = "file"
+	defaultAuthHeader     = "Authorization"
+)
+
+// Config defines a generic plugin instance. String values
+// are expanded with environment variables, so secrets can be
+// kept out of the configuration file.
+type Config struct {
+	URL            string        `json:"url"`
+	Method         string        `json:"method"`
+	AuthHeader     string        `json:"auth_header"`
+	AuthValue      string        `json:"auth_value"`
+	Body           string        `json:"body"`
+	BodyTemplate   string        `json:"body_template"`
+	MultipartField string        `json:"multipart_field"`
+	ContentType    string        `json:"content_type"`
+	Tags           *TagMapping   `json:"tags"`
+	Colors         *ColorMapping `json:"colors"`
+	Faces          *FaceMapping  `json:"faces"`
+}
+
+// TagMapping selects tags from a response. Path selects each tag,
+// Name and Score are evaluated relative to the selected tag.
+type TagMapping struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Score string `json:"score"`
+}
+
+// ColorMapping selects colors from a response. Path selects each
+// color, the other fields are evaluated relative to the selected color.
+type ColorMapping struct {
+	Path          string `json:"path"`
+	Hex           string `json:"hex"`
+	Score         string `json:"score"`
+	PixelFraction string `json:"pixel_fraction"`
+}
+
+// FaceMapping selects faces from a response. Path selects each
+// face, the other fields are evaluated relative to the selected face.
+type FaceMapping struct {
+	Path   string `json:"path"`
+	Left   string `json:"left"`
+	Top    string `json:"top"`
+	Width  string `json:"width"`
+	Height string `json:"height"`
+	Score  string `json:"score"`
+}
+
+// templateData is passed to the body template. Values are escaped
+// for use inside JSON strings.
+type templateData struct {
+	Name        string
+	Base64      string
+	ContentType string
+}
+
+// Register adds a generic plugin instance under name.
+func Register(name string, c *Config) error {
+	if c == nil {
+		return fmt.Errorf("generic plugin %q has no configuration", name)
+	}
+
+	if _, ok := visagoapi.Plugins[name]; ok {
+		return fmt.Errorf("generic plugin %q conflicts with an existing plugin", name)
+	}
+
+	visagoapi.AddPluginFactory(name, func() visagoapi.Plugin {
+		return &Plugin{
+			name:   name,
+			config: c,
+		}
+	})
+
+	return nil
+}
+
+// Plugin implements the Plugin interface for an HTTP
+// endpoint described entirely by configuration.
+type Plugin struct {
+	name       string
+	config     *Config
+	configured bool
+	template   *template.Template
+	responses  map[string]map[string]interface{}
+}
+
+// Perform gathers metadata from the configured endpoint.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	if len(c.URLs) == 0 && len(c.Files) == 0 {
+		return "", nil, fmt.Errorf("must supply files/URLs")
+	}
+
+	client := &http.Client{}
+
+	requestID := nuid.Next()
+	p.responses[requestID] = make(map[string]interface{})
+
+	items := []string{}
+	items = append(items, c.URLs...)
+	items = append(items, c.Files...)
+
+	for _, item := range items {
+		b, err := readItem(client, item)
+		if err != nil {
+			return "", nil, err
+		}
+
+		resp, err := p.request(client, item, b)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s", item, err)
+		}
+
+		p.responses[requestID][item] = resp
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	if p.responses[requestID] == nil {
+		return tags, fmt.Errorf("tag request has not been made to %s", p.name)
+	}
+
+	m := p.config.Tags
+	if m == nil {
+		return
+	}
+
+	for k, resp := range p.responses[requestID] {
+		nodes, err := lookup(m.Path, resp, resp)
+		if err != nil {
+			return tags, err
+		}
+
+		tags[k] = make(map[string]*visagoapi.PluginTagResult)
+
+		for _, node := range nodes {
+			name, err := stringValue(m.Name, resp, node)
+			if err != nil {
+				return tags, err
+			}
+
+			confidence, err := floatValue(m.Score, resp, node, 1)
+			if err != nil {
+				return tags, err
+			}
+
+			if name != "" && confidence > score {
+				tag := &visagoapi.PluginTagResult{
+					Name:  name,
+					Score: confidence,
+				}
+
+				tags[k][name] = tag
+			}
+		}
+	}
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	if p.responses[requestID] == nil {
+		return colors, fmt.Errorf("color request has not been made to %s", p.name)
+	}
+
+	m := p.config.Colors
+	if m == nil {
+		return
+	}
+
+	for k, resp := range p.responses[requestID] {
+		nodes, err := lookup(m.Path, resp, resp)
+		if err != nil {
+			return colors, err
+		}
+
+		colors[k] = make(map[string]*visagoapi.PluginColorResult)
+
+		for _, node := range nodes {
+			hex, err := stringValue(m.Hex, resp, node)
+			if err != nil {
+				return colors, err
+			}
+
+			if hex == "" {
+				continue
+			}
+
+			hex = strings.ToLower(hex)
+			if !strings.HasPrefix(hex, "#") {
+				hex = "#" + hex
+			}
+
+			cf, err := colorful.Hex(hex)
+			if err != nil {
+				return colors, err
+			}
+
+			colorScore, err := floatValue(m.Score, resp, node, 0)
+			if err != nil {
+				return colors, err
+			}
+
+			pixelFraction, err := floatValue(m.PixelFraction, resp, node, 0)
+			if err != nil {
+				return colors, err
+			}
+
+			color := &visagoapi.PluginColorResult{
+				Alpha:         1,
+				Blue:          float64(int(cf.B * 255)),
+				Green:         float64(int(cf.G * 255)),
+				Red:           float64(int(cf.R * 255)),
+				Hex:           hex,
+				Score:         colorScore,
+				PixelFraction: pixelFraction,
+			}
+
+			colors[k][hex] = color
+		}
+	}
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	if p.responses[requestID] == nil {
+		return faces, fmt.Errorf("face request has not been made to %s", p.name)
+	}
+
+	m := p.config.Faces
+	if m == nil {
+		return
+	}
+
+	for k, resp := range p.responses[requestID] {
+		nodes, err := lookup(m.Path, resp, resp)
+		if err != nil {
+			return faces, err
+		}
+
+		for _, node := range nodes {
+			box := make(map[string]int64)
+
+			for field, path := range map[string]string{"left": m.Left, "top": m.Top, "width": m.Width, "height": m.Height} {
+				v, err := floatValue(path, resp, node, 0)
+				if err != nil {
+					return faces, err
+				}
+
+				box[field] = int64(v)
+			}
+
+			detectionScore, err := floatValue(m.Score, resp, node, 0)
+			if err != nil {
+				return faces, err
+			}
+
+			left, top := box["left"], box["top"]
+			right, bottom := left+box["width"], top+box["height"]
+
+			face := &visagoapi.PluginFaceResult{
+				DetectionScore: detectionScore,
+				BoundingPoly: &visagoapi.BoundingPoly{
+					Vertices: []*visagoapi.Vertex{
+						{X: left, Y: top},
+						{X: right, Y: top},
+						{X: right, Y: bottom},
+						{X: left, Y: bottom},
+					},
+				},
+			}
+
+			faces[k] = append(faces[k], face)
+		}
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.responses = make(map[string]map[string]interface{})
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.responses {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// BatchLimits sends one item per batch. Nothing is known about
+// how the configured endpoint handles batches, so every item
+// is posted on its own.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{MaxItems: 1}
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	p.configured = false
+
+	if p.config.URL == "" {
+		return fmt.Errorf("url not found")
+	}
+
+	switch p.config.Body {
+	case "", BodyMultipart, BodyRaw:
+	case BodyBase64JSON:
+		text := p.config.BodyTemplate
+		if text == "" {
+			text = defaultBodyTemplate
+		}
+
+		t, err := template.New(p.name).Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid body template: %s", err)
+		}
+
+		p.template = t
+	default:
+		return fmt.Errorf("unsupported body type %q", p.config.Body)
+	}
+
+	p.responses = make(map[string]map[string]interface{})
+	p.configured = true
+
+	return nil
+}
+
+// request sends an item to the endpoint and decodes the JSON response.
+func (p *Plugin) request(client *http.Client, item string, b []byte) (interface{}, error) {
+	body, contentType, err := p.body(item, b)
+	if err != nil {
+		return nil, err
+	}
+
+	method := p.config.Method
+	if method == "" {
+		method = "POST"
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), os.ExpandEnv(p.config.URL), body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+
+	if p.config.AuthValue != "" {
+		header := p.config.AuthHeader
+		if header == "" {
+			header = defaultAuthHeader
+		}
+
+		req.Header.Set(header, os.ExpandEnv(p.config.AuthValue))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	var decoded interface{}
+	err = json.Unmarshal(respBody, &decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return decoded, nil
+}
+
+func (p *Plugin) body(item string, b []byte) (io.Reader, string, error) {
+	contentType := p.config.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(b)
+	}
+
+	switch p.config.Body {
+	case BodyRaw:
+		return bytes.NewReader(b), contentType, nil
+	case BodyBase64JSON:
+		var buf bytes.Buffer
+
+		data := templateData{
+			Name:        jsonEscape(filepath.Base(item)),
+			Base64:      base64.StdEncoding.EncodeToString(b),
+			ContentType: jsonEscape(contentType),
+		}
+
+		err := p.template.Execute(&buf, data)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return &buf, "application/json", nil
+	default:
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+
+		field := p.config.MultipartField
+		if field == "" {
+			field = defaultMultipartField
+		}
+
+		fw, err := w.CreateFormFile(field, filepath.Base(item))
+		if err != nil {
+			return nil, "", err
+		}
+
+		_, err = fw.Write(b)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = w.Close()
+		if err != nil {
+			return nil, "", err
+		}
+
+		return &buf, w.FormDataContentType(), nil
+	}
+}
+
+func readItem(client *http.Client, item string) ([]byte, error) {
+	if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+		resp, err := client.Get(item)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s", item, resp.Status)
+		}
+
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	return ioutil.ReadFile(item)
+}
+
+func stringValue(path string, root, node interface{}) (string, error) {
+	v, err := lookupOne(path, root, node)
+	if err != nil || v == nil {
+		return "", err
+	}
+
+	switch t := v.(type) {
+	case string:
+		return strings.TrimSpace(t), nil
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64), nil
+	default:
+		return fmt.Sprintf("%v", t), nil
+	}
+}
+
+// floatValue returns the number found at path. When path is
+// empty def is returned instead.
+func floatValue(path string, root, node interface{}, def float64) (float64, error) {
+	if strings.TrimSpace(path) == "" {
+		return def, nil
+	}
+
+	v, err := lookupOne(path, root, node)
+	if err != nil || v == nil {
+		return 0, err
+	}
+
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", t)
+		}
+
+		return f, nil
+	default:
+		return 0, fmt.Errorf("%v is not a number", t)
+	}
+}
+
+// jsonEscape escapes s for use between the quotes of a JSON string.
+func jsonEscape(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return ""
+	}
+
+	return string(b[1 : len(b)-1])
+}
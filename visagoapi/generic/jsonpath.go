@@ -0,0 +1,162 @@
+package generic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is a single step of a JSONPath style expression.
+type pathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// lookup evaluates a JSONPath style expression. Paths starting with
+// "$" are evaluated against root, all others against node. Supported
+// syntax is dotted keys, quoted keys in brackets, array indexes
+// (negative indexes count from the end) and the "*" wildcard.
+func lookup(path string, root, node interface{}) ([]interface{}, error) {
+	path = strings.TrimSpace(path)
+
+	start := node
+
+	switch {
+	case path == "" || path == "@":
+		return []interface{}{node}, nil
+	case strings.HasPrefix(path, "$"):
+		start = root
+		path = path[1:]
+	case strings.HasPrefix(path, "@"):
+		path = path[1:]
+	case !strings.HasPrefix(path, "["):
+		path = "." + path
+	}
+
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{start}
+
+	for _, step := range steps {
+		next := []interface{}{}
+
+		for _, v := range values {
+			next = append(next, step.apply(v)...)
+		}
+
+		values = next
+	}
+
+	return values, nil
+}
+
+// lookupOne returns the first value matched by path, or nil.
+func lookupOne(path string, root, node interface{}) (interface{}, error) {
+	values, err := lookup(path, root, node)
+	if err != nil || len(values) == 0 {
+		return nil, err
+	}
+
+	return values[0], nil
+}
+
+func parsePath(path string) ([]*pathStep, error) {
+	steps := []*pathStep{}
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+
+			name := path[i:end]
+			if name == "" {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+
+			if name == "*" {
+				steps = append(steps, &pathStep{wildcard: true})
+			} else {
+				steps = append(steps, &pathStep{key: name})
+			}
+
+			i = end
+		case '[':
+			end := strings.Index(path[i:], "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+
+			switch {
+			case inner == "*":
+				steps = append(steps, &pathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, &pathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in path %q", inner, path)
+				}
+
+				steps = append(steps, &pathStep{index: n, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+
+	return steps, nil
+}
+
+func (s *pathStep) apply(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			values := []interface{}{}
+			for _, mv := range t {
+				values = append(values, mv)
+			}
+
+			return values
+		}
+
+		if s.isIndex {
+			return nil
+		}
+
+		if mv, ok := t[s.key]; ok {
+			return []interface{}{mv}
+		}
+	case []interface{}:
+		if s.wildcard {
+			return t
+		}
+
+		if !s.isIndex {
+			return nil
+		}
+
+		i := s.index
+		if i < 0 {
+			i += len(t)
+		}
+
+		if i >= 0 && i < len(t) {
+			return []interface{}{t[i]}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+//go:build ignore
+// +build ignore
+
+// gen.go writes the compact cities list embedded in the geocode
+// plugin from the GeoNames dumps at
+// https://download.geonames.org/export/dump/ (CC BY 4.0).
+//
+//	go run gen.go -o cities.tsv cities15000.txt admin1CodesASCII.txt
+//
+// Capitals are always kept, other places need a population of at
+// least -min-population.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type city struct {
+	name    string
+	region  string
+	country string
+	lat     float64
+	lon     float64
+}
+
+func main() {
+	out := flag.String("o", "cities.tsv", "output file")
+	minPopulation := flag.Int("min-population", 100000, "minimum population of non capital places")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run gen.go [-o file] [-min-population n] <cities file> <admin1 file>")
+		os.Exit(2)
+	}
+
+	admin1, err := readAdmin1(flag.Arg(1))
+	if err != nil {
+		fail(err)
+	}
+
+	cities, err := readCities(flag.Arg(0), admin1, *minPopulation)
+	if err != nil {
+		fail(err)
+	}
+
+	sort.Slice(cities, func(i, j int) bool {
+		if cities[i].country != cities[j].country {
+			return cities[i].country < cities[j].country
+		}
+
+		return cities[i].name < cities[j].name
+	})
+
+	f, err := os.Create(*out)
+	if err != nil {
+		fail(err)
+	}
+
+	err = write(f, cities)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		fail(err)
+	}
+}
+
+func readAdmin1(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	admin1 := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+
+		admin1[fields[0]] = fields[1]
+	}
+
+	return admin1, scanner.Err()
+}
+
+func readCities(path string, admin1 map[string]string, minPopulation int) ([]*city, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cities := []*city{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		// geonameid, name, asciiname, alternatenames, latitude,
+		// longitude, feature class, feature code, country code,
+		// cc2, admin1 code, admin2 code, admin3 code, admin4 code,
+		// population, ...
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 15 {
+			continue
+		}
+
+		population, _ := strconv.Atoi(fields[14])
+		if fields[7] != "PPLC" && population < minPopulation {
+			continue
+		}
+
+		lat, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid latitude %q", fields[4])
+		}
+
+		lon, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid longitude %q", fields[5])
+		}
+
+		region := fields[10]
+		if name, ok := admin1[fields[8]+"."+region]; ok {
+			region = name
+		}
+
+		cities = append(cities, &city{
+			name:    fields[1],
+			region:  region,
+			country: fields[8],
+			lat:     lat,
+			lon:     lon,
+		})
+	}
+
+	return cities, scanner.Err()
+}
+
+// write stores the cities with two decimals, which is about a
+// kilometer and plenty for the nearest city.
+func write(w io.Writer, cities []*city) error {
+	bw := bufio.NewWriter(w)
+
+	for _, c := range cities {
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%.2f\t%.2f\n", c.name, c.region, c.country, c.lat, c.lon)
+	}
+
+	return bw.Flush()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
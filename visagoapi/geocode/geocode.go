@@ -0,0 +1,323 @@
+package geocode
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+	"github.com/zquestz/visago/visagoapi/metadata"
+)
+
+// cityFiles are the GeoNames cities files looked for in the
+// data directory, from the most to the least detailed.
+var cityFiles = []string{
+	"cities500.txt",
+	"cities1000.txt",
+	"cities5000.txt",
+	"cities15000.txt",
+}
+
+const admin1File = "admin1CodesASCII.txt"
+
+// defaultMaxDistance is the distance in kilometers past which
+// no city is reported when max_distance is not configured.
+const defaultMaxDistance = 100.0
+
+// trees caches the loaded GeoNames data by the file paths. The
+// trees are never modified, so every instance can share them.
+var (
+	treesMu sync.Mutex
+	trees   = make(map[string]*node)
+)
+
+func init() {
+	visagoapi.AddPluginFactory("geocode", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and resolves the EXIF
+// GPS coordinates of local files to the nearest city without
+// any network requests. URLs are skipped. Installed GeoNames
+// files take precedence over the embedded cities.
+type Plugin struct {
+	configured  bool
+	options     map[string]string
+	tree        *node
+	maxDistance float64
+	locations   map[string]map[string][]*visagoapi.PluginLocationResult
+	errors      map[string]map[string]error
+}
+
+// Configure stores the settings from the plugins section
+// of the configuration. Supported keys are cities, admin1
+// and max_distance.
+func (p *Plugin) Configure(options map[string]string) {
+	p.options = options
+}
+
+// Perform reads the GPS coordinates of each file and
+// looks up the nearest city.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.locations[requestID] = make(map[string][]*visagoapi.PluginLocationResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.LocationFeature) {
+		return requestID, p, nil
+	}
+
+	if p.tree == nil {
+		citiesPath, admin1Path, err := p.dataPaths()
+		if err != nil {
+			return "", nil, err
+		}
+
+		p.tree, err = loadTree(citiesPath, admin1Path)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	for _, file := range c.Files {
+		gps, err := metadata.GPS(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		if gps == nil {
+			continue
+		}
+
+		location, ok := p.ReverseGeocode(gps.Latitude, gps.Longitude)
+		if !ok {
+			continue
+		}
+
+		// Report the photo position rather than the city center.
+		location.Latitude = gps.Latitude
+		location.Longitude = gps.Longitude
+
+		p.locations[requestID][file] = []*visagoapi.PluginLocationResult{location}
+	}
+
+	return requestID, p, nil
+}
+
+// ReverseGeocode returns the nearest city to the coordinates.
+func (p *Plugin) ReverseGeocode(latitude, longitude float64) (*visagoapi.PluginLocationResult, bool) {
+	if p.configured == false || p.tree == nil {
+		return nil, false
+	}
+
+	best, distSq := p.tree.nearest(toPoint(latitude, longitude), nil, math.Inf(1))
+	if best == nil {
+		return nil, false
+	}
+
+	distance := chordToKm(distSq)
+	if p.maxDistance > 0 && distance > p.maxDistance {
+		return nil, false
+	}
+
+	return &visagoapi.PluginLocationResult{
+		Latitude:    best.Latitude,
+		Longitude:   best.Longitude,
+		City:        best.Name,
+		Region:      best.Region,
+		CountryCode: best.CountryCode,
+		Distance:    math.Floor(distance*100+0.5) / 100,
+	}, true
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Location returns the locations on an entry
+func (p *Plugin) Location(requestID string) (locations map[string][]*visagoapi.PluginLocationResult, err error) {
+	locations = make(map[string][]*visagoapi.PluginLocationResult)
+
+	if p.locations[requestID] == nil {
+		return locations, fmt.Errorf("location request has not been made to geocode")
+	}
+
+	for k, l := range p.locations[requestID] {
+		locations[k] = l
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to geocode")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.locations = make(map[string]map[string][]*visagoapi.PluginLocationResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.locations {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. The GeoNames data is only
+// loaded by the first request for locations.
+func (p *Plugin) Setup() error {
+	maxDistance := defaultMaxDistance
+	if v := p.options["max_distance"]; v != "" {
+		d, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			p.configured = false
+			return fmt.Errorf("invalid max_distance %q", v)
+		}
+
+		maxDistance = d
+	}
+
+	p.locations = make(map[string]map[string][]*visagoapi.PluginLocationResult)
+	p.errors = make(map[string]map[string]error)
+
+	p.tree = nil
+	p.maxDistance = maxDistance
+	p.configured = true
+
+	return nil
+}
+
+// loadTree returns the tree of the GeoNames files, loading
+// them when they are not cached yet. The embedded cities are
+// used when citiesPath is empty.
+func loadTree(citiesPath, admin1Path string) (*node, error) {
+	treesMu.Lock()
+	defer treesMu.Unlock()
+
+	key := citiesPath + "|" + admin1Path
+	if tree, ok := trees[key]; ok {
+		return tree, nil
+	}
+
+	var places []*place
+	if citiesPath == "" {
+		var err error
+		places, err = loadCompact("cities.tsv", strings.NewReader(embeddedCities))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		admin1 := make(map[string]string)
+		if admin1Path != "" {
+			var err error
+			admin1, err = loadAdmin1(admin1Path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		places, err = loadCities(citiesPath, admin1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tree := buildTree(places, 0)
+	trees[key] = tree
+
+	return tree, nil
+}
+
+// dataPaths returns the configured GeoNames files, falling back
+// to the files found in ~/.visago/geonames. Both paths are empty
+// when no cities file is installed, and the admin1 path is empty
+// when no region names are available.
+func (p *Plugin) dataPaths() (string, string, error) {
+	citiesPath := p.options["cities"]
+	admin1Path := p.options["admin1"]
+
+	if citiesPath != "" {
+		return citiesPath, admin1Path, nil
+	}
+
+	h, err := homedir.Dir()
+	if err != nil {
+		return "", "", err
+	}
+
+	dir := filepath.Join(h, ".visago", "geonames")
+
+	for _, name := range cityFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			citiesPath = path
+			break
+		}
+	}
+
+	if citiesPath == "" {
+		return "", "", nil
+	}
+
+	if admin1Path == "" {
+		path := filepath.Join(dir, admin1File)
+		if _, err := os.Stat(path); err == nil {
+			admin1Path = path
+		}
+	}
+
+	return citiesPath, admin1Path, nil
+}
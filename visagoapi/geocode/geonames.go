@@ -0,0 +1,167 @@
+package geocode
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// embeddedCities is a compact list of capitals and major cities
+// in the format written by gen.go, used when no GeoNames files are
+// installed. Run go generate with the GeoNames cities15000.txt and
+// admin1CodesASCII.txt files in this directory to rebuild it.
+//
+//go:generate go run gen.go -o cities.tsv cities15000.txt admin1CodesASCII.txt
+//go:embed cities.tsv
+var embeddedCities string
+
+// place is a populated place from a GeoNames cities file.
+type place struct {
+	Name        string
+	Region      string
+	CountryCode string
+	Latitude    float64
+	Longitude   float64
+
+	point point
+}
+
+// loadCities reads a GeoNames cities file, such as cities1000.txt.
+// Region names are looked up in admin1, and fall back to the
+// admin1 code when they are not found.
+func loadCities(path string, admin1 map[string]string) ([]*place, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	places := []*place{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		// geonameid, name, asciiname, alternatenames, latitude,
+		// longitude, feature class, feature code, country code,
+		// cc2, admin1 code, ...
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 11 {
+			continue
+		}
+
+		lat, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid latitude %q", path, line, fields[4])
+		}
+
+		lon, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid longitude %q", path, line, fields[5])
+		}
+
+		country := fields[8]
+		region := fields[10]
+		if name, ok := admin1[country+"."+region]; ok {
+			region = name
+		}
+
+		places = append(places, &place{
+			Name:        fields[1],
+			Region:      region,
+			CountryCode: country,
+			Latitude:    lat,
+			Longitude:   lon,
+			point:       toPoint(lat, lon),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(places) == 0 {
+		return nil, fmt.Errorf("%s contains no places", path)
+	}
+
+	return places, nil
+}
+
+// loadAdmin1 reads the GeoNames admin1CodesASCII.txt file,
+// keyed by the country and admin1 code, such as "US.CA".
+func loadAdmin1(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	admin1 := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+
+		admin1[fields[0]] = fields[1]
+	}
+
+	return admin1, scanner.Err()
+}
+
+// loadCompact reads the compact cities list written by gen.go.
+// Each line holds the name, region, country code, latitude and
+// longitude of a place.
+func loadCompact(name string, r io.Reader) ([]*place, error) {
+	places := []*place{}
+
+	scanner := bufio.NewScanner(r)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			continue
+		}
+
+		lat, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid latitude %q", name, line, fields[3])
+		}
+
+		lon, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid longitude %q", name, line, fields[4])
+		}
+
+		places = append(places, &place{
+			Name:        fields[0],
+			Region:      fields[1],
+			CountryCode: fields[2],
+			Latitude:    lat,
+			Longitude:   lon,
+			point:       toPoint(lat, lon),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(places) == 0 {
+		return nil, fmt.Errorf("%s contains no places", name)
+	}
+
+	return places, nil
+}
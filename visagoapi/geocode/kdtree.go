@@ -0,0 +1,103 @@
+package geocode
+
+import (
+	"math"
+	"sort"
+)
+
+// earthRadius is the mean radius of the earth in kilometers.
+const earthRadius = 6371.0
+
+// point is a place on the unit sphere. Comparing straight line
+// distances between points orders them the same way as great
+// circle distances, so a plain 3 dimensional k-d tree finds the
+// nearest place without special handling near the poles or the
+// antimeridian.
+type point [3]float64
+
+type node struct {
+	place       *place
+	point       point
+	axis        int
+	left, right *node
+}
+
+func toPoint(latitude, longitude float64) point {
+	lat := latitude * math.Pi / 180
+	lon := longitude * math.Pi / 180
+
+	return point{
+		math.Cos(lat) * math.Cos(lon),
+		math.Cos(lat) * math.Sin(lon),
+		math.Sin(lat),
+	}
+}
+
+func (p point) distanceSq(o point) float64 {
+	dx := p[0] - o[0]
+	dy := p[1] - o[1]
+	dz := p[2] - o[2]
+
+	return dx*dx + dy*dy + dz*dz
+}
+
+// buildTree builds a balanced k-d tree by splitting on the
+// median of each axis in turn.
+func buildTree(places []*place, depth int) *node {
+	if len(places) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+
+	sort.Slice(places, func(i, j int) bool {
+		return places[i].point[axis] < places[j].point[axis]
+	})
+
+	mid := len(places) / 2
+
+	return &node{
+		place: places[mid],
+		point: places[mid].point,
+		axis:  axis,
+		left:  buildTree(places[:mid], depth+1),
+		right: buildTree(places[mid+1:], depth+1),
+	}
+}
+
+// nearest returns the closest place to target and the squared
+// straight line distance to it.
+func (n *node) nearest(target point, best *place, bestDist float64) (*place, float64) {
+	if n == nil {
+		return best, bestDist
+	}
+
+	if d := n.point.distanceSq(target); best == nil || d < bestDist {
+		best = n.place
+		bestDist = d
+	}
+
+	diff := target[n.axis] - n.point[n.axis]
+
+	near, far := n.left, n.right
+	if diff > 0 {
+		near, far = n.right, n.left
+	}
+
+	best, bestDist = near.nearest(target, best, bestDist)
+
+	// Only search the other side when it can hold a closer place.
+	if diff*diff < bestDist {
+		best, bestDist = far.nearest(target, best, bestDist)
+	}
+
+	return best, bestDist
+}
+
+// chordToKm converts a squared straight line distance on the
+// unit sphere to a great circle distance in kilometers.
+func chordToKm(distSq float64) float64 {
+	chord := math.Sqrt(distSq)
+
+	return 2 * earthRadius * math.Asin(math.Min(chord/2, 1))
+}
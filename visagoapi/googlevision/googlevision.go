@@ -2,6 +2,8 @@ package googlevision
 
 import (
 	"fmt"
+	goimage "image"
+	"math"
 	"os"
 
 	"google.golang.org/api/vision/v1"
@@ -10,20 +12,32 @@ import (
 	"github.com/kaneshin/pigeon/credentials"
 	"github.com/lucasb-eyer/go-colorful"
 	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
 	"github.com/zquestz/visago/visagoapi"
 )
 
+// Vision feature types that have no pigeon.DetectionType.
+const (
+	webDetection       = "WEB_DETECTION"
+	cropHints          = "CROP_HINTS"
+	objectLocalization = "OBJECT_LOCALIZATION"
+)
+
 func init() {
-	visagoapi.AddPlugin("googlevision", &Plugin{})
+	visagoapi.AddPluginFactory("googlevision", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
 }
 
 // Plugin implements the Plugin interface and stores
 // configuration data needed by the pigeon.
 type Plugin struct {
-	configured bool
-	creds      string
-	responses  map[string]*vision.BatchAnnotateImagesResponse
-	items      map[string][]string
+	configured   bool
+	creds        string
+	responses    map[string]*vision.BatchAnnotateImagesResponse
+	items        map[string][]string
+	aspectRatios map[string][]float64
+	sizes        map[string]map[string]goimage.Point
 }
 
 // Perform gathers metadata from the Google Vision API.
@@ -49,15 +63,36 @@ func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginRes
 	features := []*vision.Feature{}
 
 	if c.EnabledFeature(visagoapi.TagsFeature) {
-		features = append(features, pigeon.NewFeature(pigeon.LabelDetection))
+		features = append(features, newFeature(c, pigeon.LabelDetection, visagoapi.TagsFeature))
 	}
 
 	if c.EnabledFeature(visagoapi.ColorsFeature) {
-		features = append(features, pigeon.NewFeature(pigeon.ImageProperties))
+		features = append(features, newFeature(c, pigeon.ImageProperties, visagoapi.ColorsFeature))
 	}
 
 	if c.EnabledFeature(visagoapi.FacesFeature) {
-		features = append(features, pigeon.NewFeature(pigeon.FaceDetection))
+		features = append(features, newFeature(c, pigeon.FaceDetection, visagoapi.FacesFeature))
+	}
+
+	if c.EnabledFeature(visagoapi.LocationFeature) {
+		features = append(features, pigeon.NewFeature(pigeon.LandmarkDetection))
+	}
+
+	if c.EnabledFeature(visagoapi.WebFeature) {
+		feature := &vision.Feature{Type: webDetection}
+		if max := c.MaxResultsFor("googlevision", visagoapi.WebFeature); max > 0 {
+			feature.MaxResults = max
+		}
+
+		features = append(features, feature)
+	}
+
+	if c.EnabledFeature(visagoapi.CropHintsFeature) {
+		features = append(features, &vision.Feature{Type: cropHints})
+	}
+
+	if c.EnabledFeature(visagoapi.ObjectsFeature) {
+		features = append(features, &vision.Feature{Type: objectLocalization})
 	}
 
 	items := []string{}
@@ -69,9 +104,33 @@ func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginRes
 		return "", nil, err
 	}
 
+	if c.EnabledFeature(visagoapi.CropHintsFeature) && len(c.AspectRatios) > 0 {
+		for _, req := range batch.Requests {
+			req.ImageContext = &vision.ImageContext{
+				CropHintsParams: &vision.CropHintsParams{
+					AspectRatios: c.AspectRatios,
+				},
+			}
+		}
+	}
+
 	requestID := nuid.Next()
 
 	p.items[requestID] = items
+	p.aspectRatios[requestID] = c.AspectRatios
+	p.sizes[requestID] = make(map[string]goimage.Point)
+
+	// Object boxes are normalized, so the size of each
+	// file is needed to convert them to pixels.
+	if c.EnabledFeature(visagoapi.ObjectsFeature) {
+		for _, file := range c.Files {
+			cfg, _, err := util.DecodeImageConfig(file)
+			if err == nil {
+				p.sizes[requestID][file] = goimage.Pt(cfg.Width, cfg.Height)
+			}
+		}
+	}
+
 	p.responses[requestID], err = client.ImagesService().Annotate(batch).Do()
 	if err != nil {
 		return "", nil, err
@@ -80,6 +139,18 @@ func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginRes
 	return requestID, p, nil
 }
 
+// newFeature builds a vision feature, applying the max results
+// configured for the matching visagoapi feature.
+func newFeature(c *visagoapi.PluginConfig, t pigeon.DetectionType, f string) *vision.Feature {
+	feature := pigeon.NewFeature(t)
+
+	if max := c.MaxResultsFor("googlevision", f); max > 0 {
+		feature.MaxResults = max
+	}
+
+	return feature
+}
+
 // Tags returns the tags on an entry
 func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
 	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
@@ -157,17 +228,8 @@ func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFa
 
 	for i, response := range p.responses[requestID].Responses {
 		for _, faceA := range response.FaceAnnotations {
-			poly := &visagoapi.BoundingPoly{}
-			for _, v := range faceA.BoundingPoly.Vertices {
-				vertex := visagoapi.Vertex{
-					X: v.X,
-					Y: v.Y,
-				}
-				poly.Vertices = append(poly.Vertices, &vertex)
-			}
-
 			face := &visagoapi.PluginFaceResult{
-				BoundingPoly:           poly,
+				BoundingPoly:           boundingPoly(faceA.BoundingPoly),
 				DetectionScore:         faceA.DetectionConfidence,
 				JoyLikelihood:          faceA.JoyLikelihood,
 				SorrowLikelihood:       faceA.SorrowLikelihood,
@@ -185,10 +247,208 @@ func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFa
 	return
 }
 
+// Web returns the web detection results on an entry
+func (p *Plugin) Web(requestID string) (web map[string]*visagoapi.PluginWebResult, err error) {
+	web = make(map[string]*visagoapi.PluginWebResult)
+
+	if p.responses[requestID] == nil {
+		return web, fmt.Errorf("web request has not been made to google")
+	}
+
+	for i, response := range p.responses[requestID].Responses {
+		wd := response.WebDetection
+		if wd == nil {
+			continue
+		}
+
+		result := &visagoapi.PluginWebResult{
+			FullMatchingImages:    webImages(wd.FullMatchingImages),
+			PartialMatchingImages: webImages(wd.PartialMatchingImages),
+			VisuallySimilarImages: webImages(wd.VisuallySimilarImages),
+		}
+
+		for _, l := range wd.BestGuessLabels {
+			result.BestGuessLabels = append(result.BestGuessLabels, l.Label)
+		}
+
+		for _, e := range wd.WebEntities {
+			entity := &visagoapi.PluginWebEntity{
+				ID:          e.EntityId,
+				Description: e.Description,
+				Score:       e.Score,
+			}
+
+			result.Entities = append(result.Entities, entity)
+		}
+
+		for _, wp := range wd.PagesWithMatchingImages {
+			page := &visagoapi.PluginWebPage{
+				URL:   wp.Url,
+				Title: wp.PageTitle,
+				Score: wp.Score,
+			}
+
+			result.PagesWithMatchingImages = append(result.PagesWithMatchingImages, page)
+		}
+
+		web[p.items[requestID][i]] = result
+	}
+
+	return
+}
+
+// CropHints returns the crop hints on an entry. Google returns
+// one hint per requested aspect ratio, in request order.
+func (p *Plugin) CropHints(requestID string) (hints map[string][]*visagoapi.PluginCropHintResult, err error) {
+	hints = make(map[string][]*visagoapi.PluginCropHintResult)
+
+	if p.responses[requestID] == nil {
+		return hints, fmt.Errorf("crop hints request has not been made to google")
+	}
+
+	ratios := p.aspectRatios[requestID]
+
+	for i, response := range p.responses[requestID].Responses {
+		if response.CropHintsAnnotation == nil {
+			continue
+		}
+
+		k := p.items[requestID][i]
+
+		for j, ch := range response.CropHintsAnnotation.CropHints {
+			hint := &visagoapi.PluginCropHintResult{
+				BoundingPoly:       boundingPoly(ch.BoundingPoly),
+				Confidence:         ch.Confidence,
+				ImportanceFraction: ch.ImportanceFraction,
+			}
+
+			if j < len(ratios) {
+				hint.AspectRatio = ratios[j]
+			}
+
+			hints[k] = append(hints[k], hint)
+		}
+	}
+
+	return
+}
+
+// Objects returns the localized objects on an entry. Objects
+// found in URLs have no bounding poly, as their size is not known.
+func (p *Plugin) Objects(requestID string) (objects map[string][]*visagoapi.PluginObjectResult, err error) {
+	objects = make(map[string][]*visagoapi.PluginObjectResult)
+
+	if p.responses[requestID] == nil {
+		return objects, fmt.Errorf("objects request has not been made to google")
+	}
+
+	for i, response := range p.responses[requestID].Responses {
+		k := p.items[requestID][i]
+		size, ok := p.sizes[requestID][k]
+
+		for _, o := range response.LocalizedObjectAnnotations {
+			object := &visagoapi.PluginObjectResult{
+				Name:  o.Name,
+				Score: o.Score,
+			}
+
+			if ok && o.BoundingPoly != nil {
+				object.BoundingPoly = normalizedPoly(o.BoundingPoly, size)
+			}
+
+			objects[k] = append(objects[k], object)
+		}
+	}
+
+	return
+}
+
+// Location returns the detected landmarks on an entry
+func (p *Plugin) Location(requestID string) (locations map[string][]*visagoapi.PluginLocationResult, err error) {
+	locations = make(map[string][]*visagoapi.PluginLocationResult)
+
+	if p.responses[requestID] == nil {
+		return locations, fmt.Errorf("location request has not been made to google")
+	}
+
+	for i, response := range p.responses[requestID].Responses {
+		k := p.items[requestID][i]
+
+		for _, annotation := range response.LandmarkAnnotations {
+			for _, l := range annotation.Locations {
+				if l.LatLng == nil {
+					continue
+				}
+
+				location := &visagoapi.PluginLocationResult{
+					Landmark:  annotation.Description,
+					Latitude:  l.LatLng.Latitude,
+					Longitude: l.LatLng.Longitude,
+					Score:     annotation.Score,
+				}
+
+				locations[k] = append(locations[k], location)
+			}
+		}
+	}
+
+	return
+}
+
+func boundingPoly(bp *vision.BoundingPoly) *visagoapi.BoundingPoly {
+	poly := &visagoapi.BoundingPoly{}
+	if bp == nil {
+		return poly
+	}
+
+	for _, v := range bp.Vertices {
+		vertex := visagoapi.Vertex{
+			X: v.X,
+			Y: v.Y,
+		}
+		poly.Vertices = append(poly.Vertices, &vertex)
+	}
+
+	return poly
+}
+
+// normalizedPoly converts the normalized vertices of bp to
+// pixels in an image of size.
+func normalizedPoly(bp *vision.BoundingPoly, size goimage.Point) *visagoapi.BoundingPoly {
+	poly := &visagoapi.BoundingPoly{}
+
+	for _, v := range bp.NormalizedVertices {
+		vertex := visagoapi.Vertex{
+			X: int64(math.Floor(v.X*float64(size.X) + 0.5)),
+			Y: int64(math.Floor(v.Y*float64(size.Y) + 0.5)),
+		}
+		poly.Vertices = append(poly.Vertices, &vertex)
+	}
+
+	return poly
+}
+
+func webImages(images []*vision.WebImage) []*visagoapi.PluginWebImage {
+	results := []*visagoapi.PluginWebImage{}
+
+	for _, i := range images {
+		image := &visagoapi.PluginWebImage{
+			URL:   i.Url,
+			Score: i.Score,
+		}
+
+		results = append(results, image)
+	}
+
+	return results
+}
+
 // Reset clears the cache of existing responses.
 func (p *Plugin) Reset() {
 	p.responses = make(map[string]*vision.BatchAnnotateImagesResponse)
 	p.items = make(map[string][]string)
+	p.aspectRatios = make(map[string][]float64)
+	p.sizes = make(map[string]map[string]goimage.Point)
 }
 
 // RequestIDs returns a list of all cached response
@@ -206,6 +466,16 @@ func (p *Plugin) RequestIDs() ([]string, error) {
 	return keys, nil
 }
 
+// BatchLimits returns the largest batch accepted by
+// images:annotate. Images are base64 encoded in a request
+// limited to 10MB, so files are kept under 7MB per batch.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{
+		MaxItems: 16,
+		MaxBytes: 7 << 20,
+	}
+}
+
 // Setup sets up the plugin for use. This should only
 // be called once per plugin.
 func (p *Plugin) Setup() error {
@@ -218,6 +488,8 @@ func (p *Plugin) Setup() error {
 
 	p.responses = make(map[string]*vision.BatchAnnotateImagesResponse)
 	p.items = make(map[string][]string)
+	p.aspectRatios = make(map[string][]float64)
+	p.sizes = make(map[string]map[string]goimage.Point)
 
 	p.creds = creds
 	p.configured = true
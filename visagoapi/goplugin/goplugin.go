@@ -0,0 +1,69 @@
+// Package goplugin loads visago plugins built with
+// go build -buildmode=plugin.
+//
+// A plugin file must export a constructor:
+//
+//	func NewPlugin() visagoapi.Plugin
+//
+// The constructor is called for every run and must return
+// a new instance each time.
+//
+// The plugin is registered under the file name without the .so extension.
+package goplugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+const (
+	// Extension is the file extension of plugin files.
+	Extension = ".so"
+
+	// Constructor is the symbol each plugin file must export.
+	Constructor = "NewPlugin"
+)
+
+// Register loads every plugin file in dir and adds the plugins
+// to visagoapi.Plugins. Existing plugins are never replaced.
+// An error is returned for each file that could not be loaded.
+func Register(dir string) []error {
+	errs := []error{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return errs
+	}
+
+	names := []string{}
+	for _, fi := range files {
+		if !fi.IsDir() && filepath.Ext(fi.Name()) == Extension {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, fileName := range names {
+		name := strings.TrimSuffix(fileName, Extension)
+
+		if _, ok := visagoapi.Plugins[name]; ok {
+			errs = append(errs, fmt.Errorf("%s: plugin %q is already registered", fileName, name))
+			continue
+		}
+
+		factory, err := load(filepath.Join(dir, fileName))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", fileName, err))
+			continue
+		}
+
+		visagoapi.AddPluginFactory(name, factory)
+	}
+
+	return errs
+}
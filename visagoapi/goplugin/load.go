@@ -0,0 +1,56 @@
+//go:build (linux && cgo) || (darwin && cgo) || (freebsd && cgo)
+// +build linux,cgo darwin,cgo freebsd,cgo
+
+package goplugin
+
+import (
+	"fmt"
+	"plugin"
+	"reflect"
+	"strings"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func load(path string) (visagoapi.PluginFactory, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		if strings.Contains(err.Error(), "different version") {
+			return nil, fmt.Errorf("version mismatch, rebuild the plugin against this version of visago: %s", err)
+		}
+
+		return nil, err
+	}
+
+	sym, err := p.Lookup(Constructor)
+	if err != nil {
+		return nil, fmt.Errorf("missing symbol %s: %s", Constructor, err)
+	}
+
+	constructor, ok := sym.(func() visagoapi.Plugin)
+	if !ok {
+		return nil, fmt.Errorf("symbol %s has type %T, expected func() visagoapi.Plugin", Constructor, sym)
+	}
+
+	if isNil(constructor()) {
+		return nil, fmt.Errorf("%s returned nil", Constructor)
+	}
+
+	return visagoapi.PluginFactory(constructor), nil
+}
+
+// isNil reports whether p is nil, including a nil pointer
+// of a concrete type stored in the interface.
+func isNil(p visagoapi.Plugin) bool {
+	if p == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(p)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+
+	return false
+}
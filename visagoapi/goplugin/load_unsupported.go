@@ -0,0 +1,15 @@
+//go:build (!linux && !darwin && !freebsd) || !cgo
+// +build !linux,!darwin,!freebsd !cgo
+
+package goplugin
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func load(path string) (visagoapi.PluginFactory, error) {
+	return nil, fmt.Errorf("go plugins are not supported on %s/%s", runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,332 @@
+package llmvision
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+const defaultPrompt = `Describe this image. Respond with only a JSON object of the form
+{"tags": [{"name": "<tag>", "confidence": <number between 0 and 1>}], "caption": "<one sentence caption>"}.
+Use short lowercase tags and do not include any other text.`
+
+func init() {
+	visagoapi.AddPluginFactory("llmvision", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface for any
+// OpenAI compatible chat completions endpoint that
+// accepts image input.
+type Plugin struct {
+	configured   bool
+	endpoint     string
+	model        string
+	apiKey       string
+	prompt       string
+	options      map[string]string
+	descriptions map[string]map[string]*description
+}
+
+// Configure stores the settings from the plugins section
+// of the configuration. Supported keys are endpoint, model,
+// api_key and prompt.
+func (p *Plugin) Configure(options map[string]string) {
+	p.options = options
+}
+
+// Perform gathers metadata from the chat completions endpoint.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	if len(c.URLs) == 0 && len(c.Files) == 0 {
+		return "", nil, fmt.Errorf("must supply files/URLs")
+	}
+
+	requestID := nuid.Next()
+	p.descriptions[requestID] = make(map[string]*description)
+
+	if !c.EnabledFeature(visagoapi.TagsFeature) && !c.EnabledFeature(visagoapi.CaptionsFeature) {
+		return requestID, p, nil
+	}
+
+	client := &http.Client{}
+
+	items := []string{}
+	items = append(items, c.URLs...)
+	items = append(items, c.Files...)
+
+	for _, item := range items {
+		dataURL, err := readDataURL(client, item)
+		if err != nil {
+			return "", nil, err
+		}
+
+		d, err := p.describe(client, dataURL)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %s", item, err)
+		}
+
+		p.descriptions[requestID][item] = d
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	if p.descriptions[requestID] == nil {
+		return tags, fmt.Errorf("tag request has not been made to llmvision")
+	}
+
+	for k, d := range p.descriptions[requestID] {
+		tags[k] = make(map[string]*visagoapi.PluginTagResult)
+
+		for _, t := range d.Tags {
+			// The reply is untrusted model output, which
+			// may contain null or unnamed tags.
+			if t == nil {
+				continue
+			}
+
+			name := strings.TrimSpace(t.Name)
+
+			if name != "" && t.Confidence > score {
+				tag := &visagoapi.PluginTagResult{
+					Name:  name,
+					Score: t.Confidence,
+				}
+
+				tags[k][name] = tag
+			}
+		}
+	}
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Captions returns the captions on an entry
+func (p *Plugin) Captions(requestID string) (captions map[string]*visagoapi.PluginCaptionResult, err error) {
+	captions = make(map[string]*visagoapi.PluginCaptionResult)
+
+	if p.descriptions[requestID] == nil {
+		return captions, fmt.Errorf("caption request has not been made to llmvision")
+	}
+
+	for k, d := range p.descriptions[requestID] {
+		caption := strings.TrimSpace(d.Caption)
+		if caption == "" {
+			continue
+		}
+
+		captions[k] = &visagoapi.PluginCaptionResult{
+			Text: caption,
+		}
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.descriptions = make(map[string]map[string]*description)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.descriptions {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// BatchLimits sends one item per batch. Each image gets its own
+// chat completion, since several images in one prompt mix up
+// their tags and overflow the context of small local models.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{MaxItems: 1}
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	endpoint := p.setting("endpoint", "LLMVISION_ENDPOINT")
+	model := p.setting("model", "LLMVISION_MODEL")
+
+	if endpoint == "" || model == "" {
+		p.configured = false
+		return fmt.Errorf("endpoint and model not found")
+	}
+
+	p.descriptions = make(map[string]map[string]*description)
+
+	p.endpoint = endpoint
+	p.model = model
+	p.apiKey = p.setting("api_key", "LLMVISION_API_KEY")
+
+	p.prompt = p.setting("prompt", "LLMVISION_PROMPT")
+	if p.prompt == "" {
+		p.prompt = defaultPrompt
+	}
+
+	p.configured = true
+
+	return nil
+}
+
+// setting reads a value from the plugin options,
+// falling back to the environment.
+func (p *Plugin) setting(option, env string) string {
+	if v := p.options[option]; v != "" {
+		return v
+	}
+
+	return os.Getenv(env)
+}
+
+func (p *Plugin) describe(client *http.Client, dataURL string) (*description, error) {
+	chatReq := &chatRequest{
+		Model: p.model,
+		Messages: []*chatMessage{
+			{
+				Role: "user",
+				Content: []*contentPart{
+					{Type: "text", Text: p.prompt},
+					{Type: "image_url", ImageURL: &imageURL{URL: dataURL}},
+				},
+			},
+		},
+	}
+
+	body, err := json.Marshal(chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(p.endpoint, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	cResp := chatResponse{}
+	err = json.Unmarshal(respBody, &cResp)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if cResp.Error != nil && cResp.Error.Message != "" {
+			return nil, fmt.Errorf("chat completion failed: %s", cResp.Error.Message)
+		}
+
+		return nil, fmt.Errorf("chat completion failed: %s", resp.Status)
+	}
+
+	if len(cResp.Choices) == 0 || cResp.Choices[0].Message == nil {
+		return nil, fmt.Errorf("chat completion returned no choices")
+	}
+
+	return parseDescription(cResp.Choices[0].Message.Content)
+}
+
+// parseDescription extracts the JSON object from the model output.
+// Models often wrap JSON in code fences or add surrounding text.
+func parseDescription(content string) (*description, error) {
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("model response did not contain JSON")
+	}
+
+	d := description{}
+	err := json.Unmarshal([]byte(content[start:end+1]), &d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse model response: %s", err)
+	}
+
+	return &d, nil
+}
+
+// readDataURL loads an item and encodes it as a data URL, as
+// not every compatible server can fetch remote images.
+func readDataURL(client *http.Client, item string) (string, error) {
+	var b []byte
+	var err error
+
+	if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+		resp, err := client.Get(item)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("failed to fetch %s: %s", item, resp.Status)
+		}
+
+		b, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		b, err = ioutil.ReadFile(item)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	contentType := http.DetectContentType(b)
+
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(b)), nil
+}
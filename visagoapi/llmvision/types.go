@@ -0,0 +1,50 @@
+package llmvision
+
+type chatRequest struct {
+	Model       string         `json:"model"`
+	Messages    []*chatMessage `json:"messages"`
+	Temperature float64        `json:"temperature"`
+}
+
+type chatMessage struct {
+	Role    string         `json:"role"`
+	Content []*contentPart `json:"content"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type chatResponse struct {
+	Choices []*chatChoice `json:"choices"`
+	Error   *chatError    `json:"error"`
+}
+
+type chatChoice struct {
+	Message *chatResponseMessage `json:"message"`
+}
+
+type chatResponseMessage struct {
+	Content string `json:"content"`
+}
+
+type chatError struct {
+	Message string `json:"message"`
+}
+
+// description is the JSON document the model is prompted to return.
+type description struct {
+	Tags    []*descriptionTag `json:"tags"`
+	Caption string            `json:"caption"`
+}
+
+type descriptionTag struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
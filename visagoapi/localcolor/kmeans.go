@@ -0,0 +1,182 @@
+package localcolor
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/lucasb-eyer/go-colorful"
+)
+
+const (
+	// Images are downsampled so the longest side is at most this many pixels.
+	sampleSize = 128
+
+	maxIterations = 20
+
+	// Pixels more transparent than this are ignored.
+	minAlpha = 0x8000
+)
+
+type lab struct {
+	L, A, B float64
+}
+
+type cluster struct {
+	Center lab
+	Count  int
+}
+
+// samplePixels downsamples img with nearest neighbour sampling
+// and returns the pixels in Lab space.
+func samplePixels(img image.Image) []lab {
+	bounds := img.Bounds()
+
+	step := 1
+	if longest := maxInt(bounds.Dx(), bounds.Dy()); longest > sampleSize {
+		step = int(math.Ceil(float64(longest) / sampleSize))
+	}
+
+	pixels := []lab{}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a < minAlpha {
+				continue
+			}
+
+			// Undo alpha premultiplication.
+			c := colorful.Color{
+				R: float64(r) / float64(a),
+				G: float64(g) / float64(a),
+				B: float64(b) / float64(a),
+			}
+
+			l, la, lb := c.Lab()
+			pixels = append(pixels, lab{l, la, lb})
+		}
+	}
+
+	return pixels
+}
+
+// kmeans groups pixels into at most k clusters. Centers are seeded
+// with k-means++ using a fixed seed so results are repeatable.
+func kmeans(pixels []lab, k int) []*cluster {
+	if len(pixels) == 0 || k <= 0 {
+		return nil
+	}
+
+	if k > len(pixels) {
+		k = len(pixels)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+
+	centers := []lab{pixels[rnd.Intn(len(pixels))]}
+	distances := make([]float64, len(pixels))
+
+	for len(centers) < k {
+		total := 0.0
+		for i, p := range pixels {
+			distances[i] = nearestDistance(p, centers)
+			total += distances[i]
+		}
+
+		// Every pixel matches an existing center.
+		if total == 0 {
+			break
+		}
+
+		target := rnd.Float64() * total
+		for i, d := range distances {
+			target -= d
+			if target <= 0 {
+				centers = append(centers, pixels[i])
+				break
+			}
+		}
+	}
+
+	assignments := make([]int, len(pixels))
+
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		changed := false
+
+		for i, p := range pixels {
+			nearest := nearestCenter(p, centers)
+			if nearest != assignments[i] {
+				assignments[i] = nearest
+				changed = true
+			}
+		}
+
+		sums := make([]lab, len(centers))
+		counts := make([]int, len(centers))
+
+		for i, p := range pixels {
+			c := assignments[i]
+			sums[c].L += p.L
+			sums[c].A += p.A
+			sums[c].B += p.B
+			counts[c]++
+		}
+
+		for c := range centers {
+			if counts[c] == 0 {
+				continue
+			}
+
+			n := float64(counts[c])
+			centers[c] = lab{sums[c].L / n, sums[c].A / n, sums[c].B / n}
+		}
+
+		if !changed && iteration > 0 {
+			break
+		}
+	}
+
+	clusters := make([]*cluster, len(centers))
+	for c := range centers {
+		clusters[c] = &cluster{Center: centers[c]}
+	}
+
+	for _, c := range assignments {
+		clusters[c].Count++
+	}
+
+	return clusters
+}
+
+func nearestCenter(p lab, centers []lab) int {
+	nearest := 0
+	best := math.MaxFloat64
+
+	for i, c := range centers {
+		if d := distance(p, c); d < best {
+			best = d
+			nearest = i
+		}
+	}
+
+	return nearest
+}
+
+func nearestDistance(p lab, centers []lab) float64 {
+	return distance(p, centers[nearestCenter(p, centers)])
+}
+
+// distance is the squared euclidean distance in Lab space.
+func distance(a, b lab) float64 {
+	dl, da, db := a.L-b.L, a.A-b.A, a.B-b.B
+	return dl*dl + da*da + db*db
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,183 @@
+package localcolor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lucasb-eyer/go-colorful"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// defaultColors is the number of colors returned
+// when max results are not configured.
+const defaultColors = 5
+
+func init() {
+	visagoapi.AddPluginFactory("localcolor", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and finds dominant
+// colors of local files without any network requests. URLs
+// are skipped.
+type Plugin struct {
+	configured bool
+	colors     map[string]map[string][]*visagoapi.PluginColorResult
+	errors     map[string]map[string]error
+}
+
+// Perform decodes each file and clusters its pixels in Lab space.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.colors[requestID] = make(map[string][]*visagoapi.PluginColorResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.ColorsFeature) {
+		return requestID, p, nil
+	}
+
+	k := int(c.MaxResultsFor("localcolor", visagoapi.ColorsFeature))
+	if k <= 0 {
+		k = defaultColors
+	}
+
+	for _, file := range c.Files {
+		img, _, err := util.DecodeImageFile(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		p.colors[requestID][file] = dominantColors(samplePixels(img), k)
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	if p.colors[requestID] == nil {
+		return colors, fmt.Errorf("color request has not been made to localcolor")
+	}
+
+	for k, results := range p.colors[requestID] {
+		colors[k] = make(map[string]*visagoapi.PluginColorResult)
+
+		for _, color := range results {
+			colors[k][color.Hex] = color
+		}
+	}
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to localcolor")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.colors = make(map[string]map[string][]*visagoapi.PluginColorResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.colors {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	p.colors = make(map[string]map[string][]*visagoapi.PluginColorResult)
+	p.errors = make(map[string]map[string]error)
+	p.configured = true
+
+	return nil
+}
+
+// dominantColors clusters the pixels into k colors. The pixel
+// fraction is the share of pixels in each cluster, and the score
+// is the cluster size relative to the largest cluster.
+func dominantColors(pixels []lab, k int) []*visagoapi.PluginColorResult {
+	results := []*visagoapi.PluginColorResult{}
+
+	clusters := kmeans(pixels, k)
+
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Count > clusters[j].Count
+	})
+
+	if len(clusters) == 0 || clusters[0].Count == 0 {
+		return results
+	}
+
+	largest := float64(clusters[0].Count)
+
+	for _, cl := range clusters {
+		if cl.Count == 0 {
+			continue
+		}
+
+		cf := colorful.Lab(cl.Center.L, cl.Center.A, cl.Center.B).Clamped()
+
+		color := &visagoapi.PluginColorResult{
+			Hex:           cf.Hex(),
+			Red:           float64(int(cf.R*255 + 0.5)),
+			Green:         float64(int(cf.G*255 + 0.5)),
+			Blue:          float64(int(cf.B*255 + 0.5)),
+			Alpha:         1,
+			PixelFraction: float64(cl.Count) / float64(len(pixels)),
+			Score:         float64(cl.Count) / largest,
+		}
+
+		results = append(results, color)
+	}
+
+	return results
+}
@@ -0,0 +1,249 @@
+package localfaces
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+const (
+	// Detection window sizes in pixels.
+	minWindow = 20
+	maxWindow = 1000
+
+	// The window moves by this fraction of its size,
+	// and grows by this factor between passes.
+	shiftFactor = 0.1
+	scaleFactor = 1.1
+
+	// Overlapping detections above this intersection
+	// over union are merged into a single face.
+	iouThreshold = 0.2
+)
+
+// cascade is a pixel intensity comparison cascade in the
+// binary format used by pigo. Each tree compares the
+// intensity of two pixels at every level, with pixel
+// offsets relative to the window center and size.
+type cascade struct {
+	treeDepth     uint32
+	treeNum       uint32
+	treeCodes     []int8
+	treePred      []float32
+	treeThreshold []float32
+}
+
+// detection is a face candidate centered on row and col,
+// with the window size in pixels and the cascade score.
+type detection struct {
+	Row   int
+	Col   int
+	Scale int
+	Q     float64
+}
+
+// unpackCascade parses a pigo cascade file.
+func unpackCascade(b []byte) (*cascade, error) {
+	// The first 8 bytes are reserved.
+	pos := 8
+
+	if len(b) < pos+8 {
+		return nil, fmt.Errorf("invalid cascade")
+	}
+
+	c := &cascade{
+		treeDepth: binary.LittleEndian.Uint32(b[pos:]),
+		treeNum:   binary.LittleEndian.Uint32(b[pos+4:]),
+	}
+	pos += 8
+
+	if c.treeDepth == 0 || c.treeDepth > 16 {
+		return nil, fmt.Errorf("invalid cascade tree depth %d", c.treeDepth)
+	}
+
+	leaves := 1 << c.treeDepth
+	codeSize := 4*leaves - 4
+	treeSize := codeSize + 4*leaves + 4
+
+	if uint64(len(b)-pos) < uint64(c.treeNum)*uint64(treeSize) {
+		return nil, fmt.Errorf("truncated cascade")
+	}
+
+	for t := 0; t < int(c.treeNum); t++ {
+		// The root node is unused, so the codes are padded
+		// to keep node indexes aligned.
+		c.treeCodes = append(c.treeCodes, 0, 0, 0, 0)
+		for _, code := range b[pos : pos+codeSize] {
+			c.treeCodes = append(c.treeCodes, int8(code))
+		}
+		pos += codeSize
+
+		for i := 0; i < leaves; i++ {
+			c.treePred = append(c.treePred, math.Float32frombits(binary.LittleEndian.Uint32(b[pos:])))
+			pos += 4
+		}
+
+		c.treeThreshold = append(c.treeThreshold, math.Float32frombits(binary.LittleEndian.Uint32(b[pos:])))
+		pos += 4
+	}
+
+	return c, nil
+}
+
+// classify runs the cascade on the window of size s centered
+// on row r and column c. A negative score rejects the window.
+func (cs *cascade) classify(r, c, s int, pixels []uint8, dim int) float32 {
+	if cs.treeNum == 0 {
+		return 0
+	}
+
+	leaves := 1 << cs.treeDepth
+	root := 0
+
+	var out float32
+
+	r *= 256
+	c *= 256
+
+	for i := 0; i < int(cs.treeNum); i++ {
+		idx := 1
+
+		for j := 0; j < int(cs.treeDepth); j++ {
+			p1 := ((r+int(cs.treeCodes[root+4*idx+0])*s)>>8)*dim + ((c + int(cs.treeCodes[root+4*idx+1])*s) >> 8)
+			p2 := ((r+int(cs.treeCodes[root+4*idx+2])*s)>>8)*dim + ((c + int(cs.treeCodes[root+4*idx+3])*s) >> 8)
+
+			idx = 2 * idx
+			if pixels[p1] <= pixels[p2] {
+				idx++
+			}
+		}
+
+		out += cs.treePred[leaves*i+idx-leaves]
+		if out <= cs.treeThreshold[i] {
+			return -1
+		}
+
+		root += 4 * leaves
+	}
+
+	return out - cs.treeThreshold[cs.treeNum-1]
+}
+
+// detect slides windows of increasing size over the grayscale
+// pixels and returns the windows accepted by the cascade.
+func (cs *cascade) detect(pixels []uint8, rows, cols int) []*detection {
+	detections := []*detection{}
+
+	maxSize := maxWindow
+	if rows < maxSize {
+		maxSize = rows
+	}
+
+	if cols < maxSize {
+		maxSize = cols
+	}
+
+	for scale := minWindow; scale <= maxSize; scale = int(float64(scale) * scaleFactor) {
+		step := int(math.Max(shiftFactor*float64(scale), 1))
+		offset := scale/2 + 1
+
+		for row := offset; row <= rows-offset; row += step {
+			for col := offset; col <= cols-offset; col += step {
+				q := cs.classify(row, col, scale, pixels, cols)
+				if q > 0 {
+					detections = append(detections, &detection{
+						Row:   row,
+						Col:   col,
+						Scale: scale,
+						Q:     float64(q),
+					})
+				}
+			}
+		}
+	}
+
+	return detections
+}
+
+// cluster merges overlapping detections, averaging their
+// position and size and summing their scores.
+func cluster(detections []*detection) []*detection {
+	sort.Slice(detections, func(i, j int) bool {
+		return detections[i].Q > detections[j].Q
+	})
+
+	clusters := []*detection{}
+	assigned := make([]bool, len(detections))
+
+	for i, d := range detections {
+		if assigned[i] {
+			continue
+		}
+
+		var row, col, scale, q float64
+		n := 0
+
+		for j := i; j < len(detections); j++ {
+			if assigned[j] || iou(d, detections[j]) <= iouThreshold {
+				continue
+			}
+
+			assigned[j] = true
+
+			row += float64(detections[j].Row)
+			col += float64(detections[j].Col)
+			scale += float64(detections[j].Scale)
+			q += detections[j].Q
+			n++
+		}
+
+		clusters = append(clusters, &detection{
+			Row:   int(row / float64(n)),
+			Col:   int(col / float64(n)),
+			Scale: int(scale / float64(n)),
+			Q:     q,
+		})
+	}
+
+	return clusters
+}
+
+// iou returns the intersection over union of two detections.
+func iou(a, b *detection) float64 {
+	ra := a.rectangle()
+	rb := b.rectangle()
+
+	inter := ra.Intersect(rb)
+	if inter.Empty() {
+		return 0
+	}
+
+	i := float64(inter.Dx() * inter.Dy())
+	u := float64(ra.Dx()*ra.Dy()+rb.Dx()*rb.Dy()) - i
+
+	return i / u
+}
+
+func (d *detection) rectangle() image.Rectangle {
+	return image.Rect(d.Col-d.Scale/2, d.Row-d.Scale/2, d.Col+d.Scale/2, d.Row+d.Scale/2)
+}
+
+// grayscale converts img to luma values, one byte per pixel.
+func grayscale(img image.Image) ([]uint8, int, int) {
+	bounds := img.Bounds()
+	rows := bounds.Dy()
+	cols := bounds.Dx()
+
+	pixels := make([]uint8, rows*cols)
+
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			pixels[y*cols+x] = uint8((299*r + 587*g + 114*b) / 1000 >> 8)
+		}
+	}
+
+	return pixels, rows, cols
+}
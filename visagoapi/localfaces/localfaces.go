@@ -0,0 +1,262 @@
+package localfaces
+
+import (
+	"embed"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// embeddedCascade holds pigo's trained facefinder cascade, which
+// is downloaded into the cascade directory by go generate.
+//
+//go:generate curl -fsSL -o cascade/facefinder https://raw.githubusercontent.com/esimov/pigo/master/cascade/facefinder
+//go:embed cascade
+var embeddedCascade embed.FS
+
+const (
+	// defaultMinScore is the cascade score a face
+	// needs when min_score is not configured.
+	defaultMinScore = 5.0
+
+	// scoreScale maps cascade scores to detection scores.
+	scoreScale = 10.0
+)
+
+func init() {
+	visagoapi.AddPluginFactory("localfaces", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and detects faces
+// in local files with a pixel intensity comparison cascade,
+// without any network requests. URLs are skipped.
+type Plugin struct {
+	configured bool
+	cascade    *cascade
+	minScore   float64
+	options    map[string]string
+	faces      map[string]map[string][]*visagoapi.PluginFaceResult
+	errors     map[string]map[string]error
+}
+
+// Configure stores the settings from the plugins section
+// of the configuration. Supported keys are cascade and
+// min_score.
+func (p *Plugin) Configure(options map[string]string) {
+	p.options = options
+}
+
+// Perform decodes each file and runs the face cascade.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.faces[requestID] = make(map[string][]*visagoapi.PluginFaceResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if p.cascade == nil || !c.EnabledFeature(visagoapi.FacesFeature) {
+		return requestID, p, nil
+	}
+
+	for _, file := range c.Files {
+		img, _, err := util.DecodeImageFile(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		pixels, rows, cols := grayscale(img)
+		bounds := img.Bounds()
+
+		for _, d := range cluster(p.cascade.detect(pixels, rows, cols)) {
+			if d.Q < p.minScore {
+				continue
+			}
+
+			r := d.rectangle().Add(bounds.Min)
+			minX, minY := int64(r.Min.X), int64(r.Min.Y)
+			maxX, maxY := int64(r.Max.X), int64(r.Max.Y)
+
+			face := &visagoapi.PluginFaceResult{
+				BoundingPoly: &visagoapi.BoundingPoly{
+					Vertices: []*visagoapi.Vertex{
+						{X: minX, Y: minY},
+						{X: maxX, Y: minY},
+						{X: maxX, Y: maxY},
+						{X: minX, Y: maxY},
+					},
+				},
+				DetectionScore: 1 - math.Exp(-d.Q/scoreScale),
+			}
+
+			p.faces[requestID][file] = append(p.faces[requestID][file], face)
+		}
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	if p.faces[requestID] == nil {
+		return faces, fmt.Errorf("face request has not been made to localfaces")
+	}
+
+	for k, f := range p.faces[requestID] {
+		faces[k] = f
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to localfaces")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.faces = make(map[string]map[string][]*visagoapi.PluginFaceResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.faces {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. Without a cascade the
+// plugin finds no faces.
+func (p *Plugin) Setup() error {
+	p.faces = make(map[string]map[string][]*visagoapi.PluginFaceResult)
+	p.errors = make(map[string]map[string]error)
+
+	b, err := p.cascadeData()
+	if err != nil {
+		p.configured = false
+		return err
+	}
+
+	if b == nil {
+		p.cascade = nil
+		p.configured = true
+
+		return nil
+	}
+
+	cs, err := unpackCascade(b)
+	if err != nil {
+		p.configured = false
+		return err
+	}
+
+	p.minScore = defaultMinScore
+	if v := p.options["min_score"]; v != "" {
+		p.minScore, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			p.configured = false
+			return fmt.Errorf("invalid min_score %q", v)
+		}
+	}
+
+	p.cascade = cs
+	p.configured = true
+
+	return nil
+}
+
+// cascadeData returns the cascade set in the options, the
+// environment or ~/.visago/cascade/facefinder, falling back
+// to the embedded cascade. It is nil when there is none.
+func (p *Plugin) cascadeData() ([]byte, error) {
+	path := p.options["cascade"]
+	if path == "" {
+		path = defaultCascadePath()
+	}
+
+	if path == "" {
+		b, err := embeddedCascade.ReadFile("cascade/facefinder")
+		if err != nil {
+			return nil, nil
+		}
+
+		return b, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cascade not found: %s", err)
+	}
+
+	return b, nil
+}
+
+// defaultCascadePath returns the cascade file set in the
+// environment, falling back to ~/.visago/cascade/facefinder
+// when it exists. It is empty when there is no cascade.
+func defaultCascadePath() string {
+	if v := os.Getenv("LOCALFACES_CASCADE"); v != "" {
+		return v
+	}
+
+	h, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+
+	path := filepath.Join(h, ".visago", "cascade", "facefinder")
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+
+	return path
+}
@@ -0,0 +1,393 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// EXIF tags read by the plugin.
+const (
+	tagMake               = 0x010F
+	tagModel              = 0x0110
+	tagOrientation        = 0x0112
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagExposureTime       = 0x829A
+	tagFNumber            = 0x829D
+	tagISO                = 0x8827
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagFocalLength        = 0x920A
+	tagLensMake           = 0xA433
+	tagLensModel          = 0xA434
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+	tagGPSAltitudeRef  = 0x0005
+	tagGPSAltitude     = 0x0006
+)
+
+// EXIF value types.
+const (
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+)
+
+var typeSizes = map[uint16]uint32{
+	typeByte:      1,
+	typeASCII:     1,
+	typeShort:     2,
+	typeLong:      4,
+	typeRational:  8,
+	typeUndefined: 1,
+	typeSLong:     4,
+	typeSRational: 8,
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// entry is a single IFD entry with its raw value.
+type entry struct {
+	typ   uint16
+	count uint32
+	data  []byte
+	order binary.ByteOrder
+}
+
+// tiff is the TIFF structure that holds EXIF data.
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// findExif returns the TIFF structure embedded in a JPEG
+// APP1 segment or a PNG eXIf chunk, or nil if there is none.
+func findExif(b []byte) []byte {
+	switch {
+	case len(b) > 2 && b[0] == 0xFF && b[1] == 0xD8:
+		return jpegExif(b)
+	case bytes.HasPrefix(b, pngSignature):
+		return pngExif(b)
+	}
+
+	return nil
+}
+
+func jpegExif(b []byte) []byte {
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return nil
+		}
+
+		marker := b[i+1]
+
+		// Padding and markers without a length.
+		if marker == 0xFF {
+			i++
+			continue
+		}
+
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			i += 2
+			continue
+		}
+
+		// Start of scan or end of image, no metadata follows.
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		if length < 2 || i+2+length > len(b) {
+			return nil
+		}
+
+		segment := b[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+
+		i += 2 + length
+	}
+
+	return nil
+}
+
+func pngExif(b []byte) []byte {
+	for i := len(pngSignature); i+8 <= len(b); {
+		length := int(binary.BigEndian.Uint32(b[i:]))
+		chunkType := string(b[i+4 : i+8])
+
+		if length < 0 || i+12+length > len(b) {
+			return nil
+		}
+
+		switch chunkType {
+		case "eXIf":
+			return b[i+8 : i+8+length]
+		case "IDAT", "IEND":
+			return nil
+		}
+
+		i += 12 + length
+	}
+
+	return nil
+}
+
+// parseExif fills m with the supported fields of the TIFF structure in b.
+func parseExif(b []byte, m *visagoapi.PluginMetadataResult) error {
+	if len(b) < 8 {
+		return fmt.Errorf("exif data too short")
+	}
+
+	t := &tiff{b: b}
+
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return fmt.Errorf("invalid exif byte order")
+	}
+
+	if t.order.Uint16(b[2:]) != 42 {
+		return fmt.Errorf("invalid exif header")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(b[4:]))
+	if err != nil {
+		return err
+	}
+
+	m.CameraMake = ifd0[tagMake].str()
+	m.CameraModel = ifd0[tagModel].str()
+	m.Orientation = ifd0[tagOrientation].int()
+
+	if e := ifd0[tagExifIFD]; e != nil {
+		exif, err := t.readIFD(uint32(e.int()))
+		if err != nil {
+			return err
+		}
+
+		m.ExposureTime = exposureTime(exif[tagExposureTime].rational(0))
+		m.FNumber = round(exif[tagFNumber].rational(0), 2)
+		m.ISO = exif[tagISO].int()
+		m.FocalLength = round(exif[tagFocalLength].rational(0), 2)
+		m.LensMake = exif[tagLensMake].str()
+		m.LensModel = exif[tagLensModel].str()
+		m.CaptureTime = captureTime(exif[tagDateTimeOriginal].str(), exif[tagOffsetTimeOriginal].str())
+	}
+
+	if e := ifd0[tagGPSIFD]; e != nil {
+		gps, err := t.readIFD(uint32(e.int()))
+		if err != nil {
+			return err
+		}
+
+		m.GPS = gpsCoordinates(gps)
+	}
+
+	return nil
+}
+
+// readIFD reads the entries of the IFD at offset.
+func (t *tiff) readIFD(offset uint32) (map[uint16]*entry, error) {
+	if uint64(offset)+2 > uint64(len(t.b)) {
+		return nil, fmt.Errorf("invalid exif IFD offset")
+	}
+
+	count := int(t.order.Uint16(t.b[offset:]))
+	entries := make(map[uint16]*entry)
+
+	for i := 0; i < count; i++ {
+		pos := uint64(offset) + 2 + uint64(i)*12
+		if pos+12 > uint64(len(t.b)) {
+			return nil, fmt.Errorf("truncated exif IFD")
+		}
+
+		raw := t.b[pos : pos+12]
+
+		tag := t.order.Uint16(raw)
+		typ := t.order.Uint16(raw[2:])
+		n := t.order.Uint32(raw[4:])
+
+		size, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+
+		total := uint64(size) * uint64(n)
+
+		var data []byte
+		if total <= 4 {
+			data = raw[8 : 8+total]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:]))
+			if valueOffset+total > uint64(len(t.b)) {
+				continue
+			}
+
+			data = t.b[valueOffset : valueOffset+total]
+		}
+
+		entries[tag] = &entry{
+			typ:   typ,
+			count: n,
+			data:  data,
+			order: t.order,
+		}
+	}
+
+	return entries, nil
+}
+
+// str returns an ASCII value without trailing NULs and spaces.
+func (e *entry) str() string {
+	if e == nil || (e.typ != typeASCII && e.typ != typeUndefined) {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(e.data), "\x00"))
+}
+
+// int returns the first integer value.
+func (e *entry) int() int {
+	if e == nil || e.count == 0 {
+		return 0
+	}
+
+	switch e.typ {
+	case typeByte, typeUndefined:
+		return int(e.data[0])
+	case typeShort:
+		return int(e.order.Uint16(e.data))
+	case typeLong:
+		return int(e.order.Uint32(e.data))
+	case typeSLong:
+		return int(int32(e.order.Uint32(e.data)))
+	}
+
+	return 0
+}
+
+// rational returns the rational value at index i as a float.
+func (e *entry) rational(i int) float64 {
+	if e == nil || uint32(i) >= e.count {
+		return 0
+	}
+
+	var num, den float64
+
+	switch e.typ {
+	case typeRational:
+		num = float64(e.order.Uint32(e.data[i*8:]))
+		den = float64(e.order.Uint32(e.data[i*8+4:]))
+	case typeSRational:
+		num = float64(int32(e.order.Uint32(e.data[i*8:])))
+		den = float64(int32(e.order.Uint32(e.data[i*8+4:])))
+	default:
+		return 0
+	}
+
+	if den == 0 {
+		return 0
+	}
+
+	return num / den
+}
+
+// exposureTime formats an exposure in seconds the way
+// cameras display it, such as 1/250 or 2.5.
+func exposureTime(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+
+	if seconds < 1 {
+		return fmt.Sprintf("1/%d", int(math.Floor(1/seconds+0.5)))
+	}
+
+	return strconv.FormatFloat(round(seconds, 1), 'f', -1, 64)
+}
+
+// captureTime converts an EXIF date to RFC3339 when the
+// offset is known, or to a local time without a zone.
+func captureTime(date, offset string) string {
+	t, err := time.Parse("2006:01:02 15:04:05", date)
+	if err != nil {
+		return ""
+	}
+
+	if offset != "" {
+		zone, err := time.Parse("-07:00", offset)
+		if err == nil {
+			_, secs := zone.Zone()
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0,
+				time.FixedZone("", secs)).Format(time.RFC3339)
+		}
+	}
+
+	return t.Format("2006-01-02T15:04:05")
+}
+
+func gpsCoordinates(gps map[uint16]*entry) *visagoapi.GPSCoordinates {
+	lat, ok := degrees(gps[tagGPSLatitude])
+	if !ok {
+		return nil
+	}
+
+	lon, ok := degrees(gps[tagGPSLongitude])
+	if !ok {
+		return nil
+	}
+
+	if strings.EqualFold(gps[tagGPSLatitudeRef].str(), "S") {
+		lat = -lat
+	}
+
+	if strings.EqualFold(gps[tagGPSLongitudeRef].str(), "W") {
+		lon = -lon
+	}
+
+	alt := gps[tagGPSAltitude].rational(0)
+	if gps[tagGPSAltitudeRef].int() == 1 {
+		alt = -alt
+	}
+
+	return &visagoapi.GPSCoordinates{
+		Latitude:  round(lat, 6),
+		Longitude: round(lon, 6),
+		Altitude:  round(alt, 2),
+	}
+}
+
+// degrees converts degrees, minutes and seconds to decimal degrees.
+func degrees(e *entry) (float64, bool) {
+	if e == nil || e.count < 3 {
+		return 0, false
+	}
+
+	return e.rational(0) + e.rational(1)/60 + e.rational(2)/3600, true
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Floor(v*p+0.5) / p
+}
@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io/ioutil"
+
+	// Register decoders used to read image dimensions.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func init() {
+	visagoapi.AddPluginFactory("metadata", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and reads file
+// and EXIF metadata of local files without any network
+// requests. URLs are skipped.
+type Plugin struct {
+	configured bool
+	metadata   map[string]map[string]*visagoapi.PluginMetadataResult
+	errors     map[string]map[string]error
+}
+
+// Perform reads the metadata of each file.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.metadata[requestID] = make(map[string]*visagoapi.PluginMetadataResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.MetadataFeature) {
+		return requestID, p, nil
+	}
+
+	for _, file := range c.Files {
+		m, err := readMetadata(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		p.metadata[requestID][file] = m
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Metadata returns the metadata on an entry
+func (p *Plugin) Metadata(requestID string) (metadata map[string]*visagoapi.PluginMetadataResult, err error) {
+	metadata = make(map[string]*visagoapi.PluginMetadataResult)
+
+	if p.metadata[requestID] == nil {
+		return metadata, fmt.Errorf("metadata request has not been made to metadata")
+	}
+
+	for k, m := range p.metadata[requestID] {
+		metadata[k] = m
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to metadata")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.metadata = make(map[string]map[string]*visagoapi.PluginMetadataResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.metadata {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	p.metadata = make(map[string]map[string]*visagoapi.PluginMetadataResult)
+	p.errors = make(map[string]map[string]error)
+	p.configured = true
+
+	return nil
+}
+
+// GPS returns the EXIF GPS coordinates of a local file,
+// or nil when the file has none.
+func GPS(path string) (*visagoapi.GPSCoordinates, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	exif := findExif(b)
+	if exif == nil {
+		return nil, nil
+	}
+
+	m := &visagoapi.PluginMetadataResult{}
+	parseExif(exif, m)
+
+	return m.GPS, nil
+}
+
+// readMetadata reads the file metadata of path. Missing or
+// invalid EXIF data is not an error, as many images have none.
+func readMetadata(path string) (*visagoapi.PluginMetadataResult, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(b)
+
+	m := &visagoapi.PluginMetadataResult{
+		Size:   int64(len(b)),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err == nil {
+		m.Format = format
+		m.Width = cfg.Width
+		m.Height = cfg.Height
+	}
+
+	if exif := findExif(b); exif != nil {
+		parseExif(exif, m)
+	}
+
+	return m, nil
+}
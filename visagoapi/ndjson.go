@@ -0,0 +1,92 @@
+package visagoapi
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// ndjsonAsset is an asset line of the NDJSON output.
+type ndjsonAsset struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	*Asset
+}
+
+// ndjsonError is an error line of the NDJSON output.
+type ndjsonError struct {
+	Type   string   `json:"type"`
+	Source string   `json:"source"`
+	Items  []string `json:"items,omitempty"`
+	Error  string   `json:"error"`
+}
+
+// ndjsonSummary is the last line of the NDJSON output.
+type ndjsonSummary struct {
+	Type    string   `json:"type"`
+	Items   int      `json:"items"`
+	Assets  int      `json:"assets"`
+	Errors  int      `json:"errors"`
+	Plugins []string `json:"plugins"`
+}
+
+// WriteNDJSON writes the events of a run as newline delimited
+// JSON. Each asset and error is written on its own line as soon
+// as it arrives, followed by a summary line. It stops reading
+// the events when a line fails to write, so the caller should
+// cancel the context of the stream once it returns.
+func WriteNDJSON(w io.Writer, events <-chan *Event) error {
+	enc := json.NewEncoder(w)
+
+	var err error
+	write := func(v interface{}) {
+		if err == nil {
+			err = enc.Encode(v)
+		}
+	}
+
+	items := make(map[string]bool)
+	summary := ndjsonSummary{
+		Type:    "summary",
+		Plugins: []string{},
+	}
+
+	for e := range events {
+		if err != nil {
+			return err
+		}
+
+		switch e.Type {
+		case ItemEvent:
+			items[e.Item] = true
+
+			if e.Asset == nil {
+				continue
+			}
+
+			summary.Assets++
+			write(&ndjsonAsset{
+				Type:   "asset",
+				Source: e.Plugin,
+				Asset:  e.Asset,
+			})
+		case ErrorEvent:
+			summary.Errors++
+			write(&ndjsonError{
+				Type:   "error",
+				Source: e.Plugin,
+				Items:  e.Items,
+				Error:  e.Error,
+			})
+		case PluginEvent:
+			summary.Plugins = append(summary.Plugins, e.Plugin)
+		}
+	}
+
+	sort.Strings(summary.Plugins)
+	summary.Items = len(items)
+
+	write(&summary)
+
+	return err
+}
@@ -0,0 +1,157 @@
+package phash
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// Sizes of the grayscale thumbnails each hash is built from.
+const (
+	hashSize = 8
+	dctSize  = 32
+)
+
+// averageHash sets a bit for each pixel of an 8x8
+// thumbnail that is brighter than the mean.
+func averageHash(img image.Image) uint64 {
+	pixels := thumbnail(img, hashSize, hashSize)
+
+	var sum float64
+	for _, v := range pixels {
+		sum += v
+	}
+
+	mean := sum / float64(len(pixels))
+
+	var hash uint64
+	for _, v := range pixels {
+		hash <<= 1
+		if v > mean {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+// differenceHash sets a bit for each pixel of a 9x8 thumbnail
+// that is darker than its right neighbour.
+func differenceHash(img image.Image) uint64 {
+	w := hashSize + 1
+	pixels := thumbnail(img, w, hashSize)
+
+	var hash uint64
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			hash <<= 1
+			if pixels[y*w+x] < pixels[y*w+x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// perceptualHash sets a bit for each of the 8x8 lowest
+// frequencies of the DCT of a 32x32 thumbnail that is above
+// the median. The DC term is left out of the median, as it
+// only reflects the overall brightness.
+func perceptualHash(img image.Image) uint64 {
+	coeffs := dct(thumbnail(img, dctSize, dctSize), dctSize)
+
+	low := make([]float64, 0, hashSize*hashSize)
+	for y := 0; y < hashSize; y++ {
+		for x := 0; x < hashSize; x++ {
+			low = append(low, coeffs[y*dctSize+x])
+		}
+	}
+
+	sorted := make([]float64, len(low)-1)
+	copy(sorted, low[1:])
+	sort.Float64s(sorted)
+
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for _, v := range low {
+		hash <<= 1
+		if v > median {
+			hash |= 1
+		}
+	}
+
+	return hash
+}
+
+// thumbnail scales img to w x h grayscale pixels, averaging
+// the source pixels that fall in each cell.
+func thumbnail(img image.Image, w, h int) []float64 {
+	bounds := img.Bounds()
+	pixels := make([]float64, w*h)
+	counts := make([]float64, w*h)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ty := (y - bounds.Min.Y) * h / bounds.Dy()
+
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			tx := (x - bounds.Min.X) * w / bounds.Dx()
+
+			r, g, b, _ := img.At(x, y).RGBA()
+
+			pixels[ty*w+tx] += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+			counts[ty*w+tx]++
+		}
+	}
+
+	for i := range pixels {
+		if counts[i] > 0 {
+			pixels[i] /= counts[i]
+		}
+	}
+
+	return pixels
+}
+
+// dct returns the 2D type II discrete cosine transform
+// of the n x n pixels.
+func dct(pixels []float64, n int) []float64 {
+	cos := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			cos[k*n+i] = math.Cos(math.Pi * float64(k) * (2*float64(i) + 1) / (2 * float64(n)))
+		}
+	}
+
+	rows := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				sum += pixels[y*n+x] * cos[k*n+x]
+			}
+
+			rows[y*n+k] = sum
+		}
+	}
+
+	out := make([]float64, n*n)
+	for x := 0; x < n; x++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				sum += rows[y*n+x] * cos[k*n+y]
+			}
+
+			out[k*n+x] = sum
+		}
+	}
+
+	return out
+}
+
+func hex(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
@@ -0,0 +1,138 @@
+package phash
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func init() {
+	visagoapi.AddPluginFactory("phash", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and computes
+// perceptual hashes of local files without any network
+// requests. URLs are skipped.
+type Plugin struct {
+	configured bool
+	hashes     map[string]map[string]*visagoapi.PluginHashResult
+	errors     map[string]map[string]error
+}
+
+// Perform decodes each file and computes its hashes.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.hashes[requestID] = make(map[string]*visagoapi.PluginHashResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.HashesFeature) {
+		return requestID, p, nil
+	}
+
+	for _, file := range c.Files {
+		img, _, err := util.DecodeImageFile(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		p.hashes[requestID][file] = &visagoapi.PluginHashResult{
+			AHash: hex(averageHash(img)),
+			DHash: hex(differenceHash(img)),
+			PHash: hex(perceptualHash(img)),
+		}
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Hashes returns the perceptual hashes on an entry
+func (p *Plugin) Hashes(requestID string) (hashes map[string]*visagoapi.PluginHashResult, err error) {
+	hashes = make(map[string]*visagoapi.PluginHashResult)
+
+	if p.hashes[requestID] == nil {
+		return hashes, fmt.Errorf("hash request has not been made to phash")
+	}
+
+	for k, h := range p.hashes[requestID] {
+		hashes[k] = h
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to phash")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.hashes = make(map[string]map[string]*visagoapi.PluginHashResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.hashes {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	p.hashes = make(map[string]map[string]*visagoapi.PluginHashResult)
+	p.errors = make(map[string]map[string]error)
+	p.configured = true
+
+	return nil
+}
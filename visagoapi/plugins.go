@@ -1,28 +1,54 @@
 package visagoapi
 
-import (
-	"fmt"
-	"sort"
-	"strings"
-)
+import "sync"
 
 const (
+	// CaptionsFeature is the value to enable the caption features.
+	CaptionsFeature = "captions"
+
+	// CodesFeature is the value to enable the barcode and QR code features.
+	// It is not part of the default features and must be requested.
+	CodesFeature = "codes"
+
+	// CropHintsFeature is the value to enable the crop hint features.
+	// It is not part of the default features and must be requested.
+	CropHintsFeature = "crop_hints"
+
 	// ColorsFeature is the value to enable the color features.
 	ColorsFeature = "colors"
 
+	// HashesFeature is the value to enable the perceptual hash features.
+	// It is not part of the default features and must be requested.
+	HashesFeature = "hashes"
+
+	// LocationFeature is the value to enable the location features.
+	// It is not part of the default features and must be requested.
+	LocationFeature = "location"
+
+	// ObjectsFeature is the value to enable the object localization features.
+	// It is not part of the default features and must be requested.
+	ObjectsFeature = "objects"
+
+	// MetadataFeature is the value to enable the image metadata features.
+	MetadataFeature = "metadata"
+
 	// FacesFeature is the value to enable the face detection features.
 	FacesFeature = "faces"
 
+	// QualityFeature is the value to enable the image quality features.
+	// It is not part of the default features and must be requested.
+	QualityFeature = "quality"
+
 	// TagsFeature is the value to enable the tagging features.
 	TagsFeature = "tags"
+
+	// WebFeature is the value to enable the web detection features.
+	// It is not part of the default features and must be requested.
+	WebFeature = "web"
 )
 
 var (
-	enableBlacklist = false
-	enableWhitelist = false
-	blacklist       = make(map[string]interface{})
-	whitelist       = make(map[string]interface{})
-	defaultFeatures = []string{ColorsFeature, FacesFeature, TagsFeature}
+	defaultFeatures = []string{CaptionsFeature, ColorsFeature, FacesFeature, MetadataFeature, TagsFeature}
 )
 
 // Plugin interface provides a way to query
@@ -46,6 +72,109 @@ type PluginResult interface {
 	Colors(string) (map[string]map[string]*PluginColorResult, error)
 }
 
+// WebPluginResult is implemented by plugin results that
+// support web detection. Requires the requestID returned
+// from Perform().
+type WebPluginResult interface {
+	Web(string) (map[string]*PluginWebResult, error)
+}
+
+// CaptionsPluginResult is implemented by plugin results that
+// support captions. Requires the requestID returned
+// from Perform().
+type CaptionsPluginResult interface {
+	Captions(string) (map[string]*PluginCaptionResult, error)
+}
+
+// ConfigurablePlugin is implemented by plugins that accept
+// settings from PluginConfig.Options. Configure is called
+// with the options for the plugin before Setup().
+type ConfigurablePlugin interface {
+	Configure(map[string]string)
+}
+
+// BatchingPlugin is implemented by plugins that limit how
+// many items they accept in a single Perform() call. Larger
+// inputs are split into chunks that respect the limits.
+type BatchingPlugin interface {
+	BatchLimits() BatchLimits
+}
+
+// BatchLimits are the largest batch a plugin accepts.
+// Zero means there is no limit.
+type BatchLimits struct {
+	// MaxItems is the number of URLs and files per batch.
+	MaxItems int
+
+	// MaxBytes is the combined size of the files per batch.
+	// A file larger than MaxBytes is sent on its own.
+	MaxBytes int64
+}
+
+// ItemErrorsPluginResult is implemented by plugin results that
+// can fail on single items without failing the whole batch. The
+// errors are keyed by item. Requires the requestID returned from
+// Perform().
+type ItemErrorsPluginResult interface {
+	ItemErrors(string) (map[string]error, error)
+}
+
+// CodesPluginResult is implemented by plugin results that
+// support barcode and QR code detection. Requires the
+// requestID returned from Perform().
+type CodesPluginResult interface {
+	Codes(string) (map[string][]*PluginCodeResult, error)
+}
+
+// CropHintsPluginResult is implemented by plugin results that
+// support crop hints. Requires the requestID returned
+// from Perform().
+type CropHintsPluginResult interface {
+	CropHints(string) (map[string][]*PluginCropHintResult, error)
+}
+
+// ObjectsPluginResult is implemented by plugin results that
+// support object localization. Requires the requestID returned
+// from Perform().
+type ObjectsPluginResult interface {
+	Objects(string) (map[string][]*PluginObjectResult, error)
+}
+
+// HashesPluginResult is implemented by plugin results that
+// support perceptual hashes. Requires the requestID returned
+// from Perform().
+type HashesPluginResult interface {
+	Hashes(string) (map[string]*PluginHashResult, error)
+}
+
+// LocationPluginResult is implemented by plugin results that
+// support locations. Requires the requestID returned from
+// Perform().
+type LocationPluginResult interface {
+	Location(string) (map[string][]*PluginLocationResult, error)
+}
+
+// ReverseGeocoder is implemented by plugins that can resolve
+// coordinates to a place. It is used to add the place to the
+// merged locations of other plugins, such as landmarks.
+type ReverseGeocoder interface {
+	ReverseGeocode(latitude, longitude float64) (*PluginLocationResult, bool)
+}
+
+// MetadataPluginResult is implemented by plugin results that
+// support image metadata. Requires the requestID returned
+// from Perform().
+type MetadataPluginResult interface {
+	Metadata(string) (map[string]*PluginMetadataResult, error)
+}
+
+// QualityPluginResult is implemented by plugin results that
+// support image quality metrics. Requires the requestID
+// returned from Perform().
+type QualityPluginResult interface {
+	Quality(string) (map[string]*PluginQualityResult, error)
+}
+
 // PluginTagResult are the attributes on a tag. The score
 // is a value from 0 and 1.
 type PluginTagResult struct {
@@ -58,6 +187,7 @@ type PluginTagResult struct {
 
 // PluginColorResult are the attributes for a color.
 type PluginColorResult struct {
+	Name          string  `json:"name,omitempty"`
 	Hex           string  `json:"hex,omitempty"`
 	Score         float64 `json:"score,omitempty"`
 	PixelFraction float64 `json:"pixel_fraction,omitempty"`
@@ -86,6 +216,158 @@ type PluginFaceResult struct {
 	Source string `json:"source,omitempty"`
 }
 
+// PluginWebResult are the web detection results for an asset.
+type PluginWebResult struct {
+	BestGuessLabels         []string           `json:"best_guess_labels,omitempty"`
+	Entities                []*PluginWebEntity `json:"entities,omitempty"`
+	FullMatchingImages      []*PluginWebImage  `json:"full_matching_images,omitempty"`
+	PartialMatchingImages   []*PluginWebImage  `json:"partial_matching_images,omitempty"`
+	VisuallySimilarImages   []*PluginWebImage  `json:"visually_similar_images,omitempty"`
+	PagesWithMatchingImages []*PluginWebPage   `json:"pages_with_matching_images,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginWebEntity is an entity inferred from similar images on the web.
+type PluginWebEntity struct {
+	ID          string  `json:"id,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Score       float64 `json:"score,omitempty"`
+}
+
+// PluginWebImage is an image found on the web.
+type PluginWebImage struct {
+	URL   string  `json:"url,omitempty"`
+	Score float64 `json:"score,omitempty"`
+}
+
+// PluginWebPage is a web page containing a matching image.
+type PluginWebPage struct {
+	URL   string  `json:"url,omitempty"`
+	Title string  `json:"title,omitempty"`
+	Score float64 `json:"score,omitempty"`
+}
+
+// PluginCaptionResult is a short description of an asset.
+type PluginCaptionResult struct {
+	Text  string  `json:"text,omitempty"`
+	Score float64 `json:"score,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginCodeResult is a barcode or QR code found in an asset.
+type PluginCodeResult struct {
+	Symbology    string        `json:"symbology,omitempty"`
+	Payload      string        `json:"payload,omitempty"`
+	BoundingPoly *BoundingPoly `json:"bounding_poly,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginCropHintResult is a suggested crop for an asset.
+type PluginCropHintResult struct {
+	BoundingPoly       *BoundingPoly `json:"bounding_poly,omitempty"`
+	AspectRatio        float64       `json:"aspect_ratio,omitempty"`
+	Confidence         float64       `json:"confidence,omitempty"`
+	ImportanceFraction float64       `json:"importance_fraction,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginObjectResult is an object found in an asset. BoundingPoly
+// is nil when the size of the asset is not known.
+type PluginObjectResult struct {
+	Name         string        `json:"name,omitempty"`
+	Score        float64       `json:"score,omitempty"`
+	BoundingPoly *BoundingPoly `json:"bounding_poly,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginHashResult are the perceptual hashes of an asset. Each
+// hash is 64 bits encoded as 16 hex characters. Similar images
+// have hashes with a small Hamming distance.
+type PluginHashResult struct {
+	AHash string `json:"ahash,omitempty"`
+	DHash string `json:"dhash,omitempty"`
+	PHash string `json:"phash,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginLocationResult is a place an asset was taken at or shows.
+// Landmark is set when the location comes from landmark detection,
+// and Distance is the distance in kilometers to the nearest city.
+type PluginLocationResult struct {
+	Landmark    string  `json:"landmark,omitempty"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	City        string  `json:"city,omitempty"`
+	Region      string  `json:"region,omitempty"`
+	CountryCode string  `json:"country_code,omitempty"`
+	Distance    float64 `json:"distance,omitempty"`
+	Score       float64 `json:"score,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginMetadataResult is the file and EXIF metadata of an asset.
+type PluginMetadataResult struct {
+	Format       string          `json:"format,omitempty"`
+	Width        int             `json:"width,omitempty"`
+	Height       int             `json:"height,omitempty"`
+	Size         int64           `json:"size,omitempty"`
+	SHA256       string          `json:"sha256,omitempty"`
+	CameraMake   string          `json:"camera_make,omitempty"`
+	CameraModel  string          `json:"camera_model,omitempty"`
+	LensMake     string          `json:"lens_make,omitempty"`
+	LensModel    string          `json:"lens_model,omitempty"`
+	CaptureTime  string          `json:"capture_time,omitempty"`
+	ExposureTime string          `json:"exposure_time,omitempty"`
+	FNumber      float64         `json:"f_number,omitempty"`
+	ISO          int             `json:"iso,omitempty"`
+	FocalLength  float64         `json:"focal_length,omitempty"`
+	Orientation  int             `json:"orientation,omitempty"`
+	GPS          *GPSCoordinates `json:"gps,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// PluginQualityResult are the whole image quality metrics of an asset.
+// Brightness, contrast and clipping are fractions between 0 and 1,
+// sharpness is the variance of the Laplacian and noise is the
+// estimated standard deviation of the noise in 8 bit luma units.
+type PluginQualityResult struct {
+	Sharpness         float64  `json:"sharpness"`
+	Brightness        float64  `json:"brightness"`
+	Contrast          float64  `json:"contrast"`
+	Noise             float64  `json:"noise"`
+	ShadowClipping    float64  `json:"shadow_clipping"`
+	HighlightClipping float64  `json:"highlight_clipping"`
+	Pass              bool     `json:"pass"`
+	Failures          []string `json:"failures,omitempty"`
+
+	// Source should not be set directly by a plugin.
+	Source string `json:"source,omitempty"`
+}
+
+// GPSCoordinates is a position in decimal degrees, with
+// the altitude in meters above sea level.
+type GPSCoordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude,omitempty"`
+}
+
 // PluginConfig is used to pass configuration
 // data to plugins when they load.
 type PluginConfig struct {
@@ -94,6 +376,22 @@ type PluginConfig struct {
 	Verbose  bool     `json:"verbose"`
 	TagScore float64  `json:"tag_score"`
 	Features []string `json:"features"`
+
+	// MaxResults limits the number of results returned per
+	// feature, keyed by plugin name and then feature name.
+	// Plugins that support it use their own default when unset.
+	MaxResults map[string]map[string]int64 `json:"max_results,omitempty"`
+
+	// AspectRatios are the width/height ratios
+	// requested from the crop hints feature.
+	AspectRatios []float64 `json:"aspect_ratios,omitempty"`
+
+	// Options stores plugin specific settings keyed by plugin name.
+	Options map[string]map[string]string `json:"options,omitempty"`
+
+	// Concurrency is the number of batches run at the same
+	// time. Zero uses DefaultConcurrency.
+	Concurrency int `json:"concurrency,omitempty"`
 }
 
 // EnabledFeature lets you check if a particular feature
@@ -114,71 +412,72 @@ func (p *PluginConfig) EnabledFeature(f string) bool {
 	return false
 }
 
+// MaxResultsFor returns the maximum number of results requested
+// from a plugin for a feature. Zero means the plugin default
+// should be used.
+func (p *PluginConfig) MaxResultsFor(plugin, f string) int64 {
+	return p.MaxResults[plugin][f]
+}
+
+// PluginFactory returns a new plugin instance. Every run gets
+// its own instance, so concurrent runs never share state.
+type PluginFactory func() Plugin
+
 // Plugins tracks loaded plugins.
-var Plugins map[string]Plugin
+var Plugins map[string]PluginFactory
 
 func init() {
-	Plugins = make(map[string]Plugin)
+	Plugins = make(map[string]PluginFactory)
 }
 
-// AddPlugin should be called within your plugin's init() func.
-// This will register the plugin so it can be used.
+// AddPluginFactory should be called within your plugin's init()
+// func. This will register the plugin so it can be used.
+func AddPluginFactory(name string, factory PluginFactory) {
+	defaultClient.AddPluginFactory(name, factory)
+}
+
+// AddPlugin registers a single plugin instance. The instance is
+// shared by every run, so concurrent runs of it are serialized.
+// Use AddPluginFactory for plugins that can run in parallel.
 func AddPlugin(name string, plugin Plugin) {
-	Plugins[name] = plugin
+	defaultClient.AddPlugin(name, plugin)
 }
 
-// SetBlacklist filters out unneeded plugins.
-func SetBlacklist(b []string) {
-	for _, v := range b {
-		blacklist[v] = nil
-	}
+// sharedPlugin is a plugin instance registered without a
+// factory. Runs hold its lock while they use the instance.
+type sharedPlugin struct {
+	sync.Mutex
+	Plugin
+}
+
+// sharedFactory returns a factory that always returns plugin.
+func sharedFactory(plugin Plugin) PluginFactory {
+	s := &sharedPlugin{Plugin: plugin}
 
-	if len(blacklist) > 0 {
-		enableBlacklist = true
+	return func() Plugin {
+		return s
 	}
 }
 
-// SetWhitelist sets an exact list of supported plugins.
-func SetWhitelist(w []string) {
-	for _, v := range w {
-		whitelist[v] = nil
-	}
+// SetBlacklist filters out unneeded plugins. It replaces the
+// previous blacklist, and an empty list clears it.
+func SetBlacklist(b []string) {
+	defaultClient.SetBlacklist(b)
+}
 
-	if len(whitelist) > 0 {
-		enableWhitelist = true
-	}
+// SetWhitelist sets an exact list of supported plugins. It replaces
+// the previous whitelist, and an empty list clears it.
+func SetWhitelist(w []string) {
+	defaultClient.SetWhitelist(w)
 }
 
 // DisplayPlugins displays all the loaded plugins.
 func DisplayPlugins() string {
-	names := PluginNames()
-
-	return fmt.Sprintf("%s\n", strings.Join(names, "\n"))
+	return defaultClient.DisplayPlugins()
 }
 
 // PluginNames returns a sorted slice of plugin names
 // applying both the whitelist and then the blacklist.
 func PluginNames() []string {
-	names := []string{}
-
-	for key := range Plugins {
-		if enableWhitelist {
-			_, ok := whitelist[key]
-			if !ok {
-				continue
-			}
-		}
-
-		if enableBlacklist {
-			_, ok := blacklist[key]
-			if ok {
-				continue
-			}
-		}
-
-		names = append(names, key)
-	}
-
-	sort.Strings(names)
-	return names
+	return defaultClient.PluginNames()
 }
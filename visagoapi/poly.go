@@ -1,5 +1,7 @@
 package visagoapi
 
+import "image"
+
 // BoundingPoly is used to store the
 // vertexes marking the postition of the face.
 type BoundingPoly struct {
@@ -11,3 +13,37 @@ type Vertex struct {
 	X int64 `json:"x"`
 	Y int64 `json:"y"`
 }
+
+// Rectangle returns the smallest rectangle containing every vertex.
+func (b *BoundingPoly) Rectangle() image.Rectangle {
+	r := image.Rectangle{}
+
+	if b == nil || len(b.Vertices) == 0 {
+		return r
+	}
+
+	r.Min = image.Pt(int(b.Vertices[0].X), int(b.Vertices[0].Y))
+	r.Max = r.Min
+
+	for _, v := range b.Vertices[1:] {
+		x, y := int(v.X), int(v.Y)
+
+		if x < r.Min.X {
+			r.Min.X = x
+		}
+
+		if y < r.Min.Y {
+			r.Min.Y = y
+		}
+
+		if x > r.Max.X {
+			r.Max.X = x
+		}
+
+		if y > r.Max.Y {
+			r.Max.Y = y
+		}
+	}
+
+	return r
+}
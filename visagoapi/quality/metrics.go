@@ -0,0 +1,164 @@
+package quality
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// Images are downsampled so the longest side is at most this
+	// many pixels, keeping sharpness comparable between sizes.
+	sampleSize = 1024
+
+	// Luma values at or beyond these are counted as clipped.
+	shadowLevel    = 2
+	highlightLevel = 253
+)
+
+// metrics are the raw measurements of an image.
+type metrics struct {
+	Sharpness         float64
+	Brightness        float64
+	Contrast          float64
+	Noise             float64
+	ShadowClipping    float64
+	HighlightClipping float64
+}
+
+// luma is a grayscale image with values between 0 and 255.
+type luma struct {
+	Pix    []float64
+	Width  int
+	Height int
+}
+
+func (l *luma) at(x, y int) float64 {
+	return l.Pix[y*l.Width+x]
+}
+
+// measure computes the quality metrics of img.
+func measure(img image.Image) *metrics {
+	l := sampleLuma(img)
+	m := &metrics{}
+
+	n := float64(len(l.Pix))
+	if n == 0 {
+		return m
+	}
+
+	var sum, sumSq float64
+	var shadows, highlights int
+
+	for _, v := range l.Pix {
+		sum += v
+		sumSq += v * v
+
+		if v <= shadowLevel {
+			shadows++
+		}
+
+		if v >= highlightLevel {
+			highlights++
+		}
+	}
+
+	mean := sum / n
+
+	m.Brightness = mean / 255
+	m.Contrast = math.Sqrt(math.Max(sumSq/n-mean*mean, 0)) / 255
+	m.ShadowClipping = float64(shadows) / n
+	m.HighlightClipping = float64(highlights) / n
+	m.Sharpness = laplacianVariance(l)
+	m.Noise = noiseSigma(l)
+
+	return m
+}
+
+// sampleLuma converts img to luma, averaging blocks of
+// pixels when the image is larger than sampleSize.
+func sampleLuma(img image.Image) *luma {
+	bounds := img.Bounds()
+
+	step := 1
+	if longest := maxInt(bounds.Dx(), bounds.Dy()); longest > sampleSize {
+		step = int(math.Ceil(float64(longest) / sampleSize))
+	}
+
+	l := &luma{
+		Width:  bounds.Dx() / step,
+		Height: bounds.Dy() / step,
+	}
+	l.Pix = make([]float64, l.Width*l.Height)
+
+	for y := 0; y < l.Height; y++ {
+		for x := 0; x < l.Width; x++ {
+			var total float64
+
+			for dy := 0; dy < step; dy++ {
+				for dx := 0; dx < step; dx++ {
+					r, g, b, _ := img.At(bounds.Min.X+x*step+dx, bounds.Min.Y+y*step+dy).RGBA()
+					total += (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+				}
+			}
+
+			l.Pix[y*l.Width+x] = total / float64(step*step)
+		}
+	}
+
+	return l
+}
+
+// laplacianVariance returns the variance of the 4 neighbour
+// Laplacian. Blurry images have few edges and a low variance.
+func laplacianVariance(l *luma) float64 {
+	if l.Width < 3 || l.Height < 3 {
+		return 0
+	}
+
+	var sum, sumSq float64
+	n := float64((l.Width - 2) * (l.Height - 2))
+
+	for y := 1; y < l.Height-1; y++ {
+		for x := 1; x < l.Width-1; x++ {
+			v := l.at(x-1, y) + l.at(x+1, y) + l.at(x, y-1) + l.at(x, y+1) - 4*l.at(x, y)
+
+			sum += v
+			sumSq += v * v
+		}
+	}
+
+	mean := sum / n
+
+	return sumSq/n - mean*mean
+}
+
+// noiseSigma estimates the standard deviation of additive noise
+// with the method from Immerkaer, "Fast Noise Variance Estimation".
+// The mask cancels out edges and smooth gradients, leaving noise.
+func noiseSigma(l *luma) float64 {
+	if l.Width < 3 || l.Height < 3 {
+		return 0
+	}
+
+	var sum float64
+
+	for y := 1; y < l.Height-1; y++ {
+		for x := 1; x < l.Width-1; x++ {
+			v := l.at(x-1, y-1) - 2*l.at(x, y-1) + l.at(x+1, y-1) -
+				2*l.at(x-1, y) + 4*l.at(x, y) - 2*l.at(x+1, y) +
+				l.at(x-1, y+1) - 2*l.at(x, y+1) + l.at(x+1, y+1)
+
+			sum += math.Abs(v)
+		}
+	}
+
+	return sum * math.Sqrt(math.Pi/2) / (6 * float64(l.Width-2) * float64(l.Height-2))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,215 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/util"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+// defaultThresholds decide if an image passes. Each key is also
+// the name of the setting in the plugins section.
+var defaultThresholds = map[string]float64{
+	"min_sharpness":          100,
+	"min_brightness":         0.15,
+	"max_brightness":         0.85,
+	"min_contrast":           0.1,
+	"max_noise":              10,
+	"max_shadow_clipping":    0.1,
+	"max_highlight_clipping": 0.1,
+}
+
+func init() {
+	visagoapi.AddPluginFactory("quality", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and measures the
+// quality of local files without any network requests.
+// URLs are skipped.
+type Plugin struct {
+	configured bool
+	options    map[string]string
+	thresholds map[string]float64
+	quality    map[string]map[string]*visagoapi.PluginQualityResult
+	errors     map[string]map[string]error
+}
+
+// Configure stores the settings from the plugins section
+// of the configuration. Supported keys are the pass/fail
+// thresholds, such as min_sharpness and max_noise.
+func (p *Plugin) Configure(options map[string]string) {
+	p.options = options
+}
+
+// Perform decodes each file and measures its quality.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	requestID := nuid.Next()
+	p.quality[requestID] = make(map[string]*visagoapi.PluginQualityResult)
+	p.errors[requestID] = make(map[string]error)
+
+	if !c.EnabledFeature(visagoapi.QualityFeature) {
+		return requestID, p, nil
+	}
+
+	for _, file := range c.Files {
+		img, _, err := util.DecodeImageFile(file)
+		if err != nil {
+			p.errors[requestID][file] = err
+			continue
+		}
+
+		p.quality[requestID][file] = p.result(measure(img))
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	return
+}
+
+// Quality returns the quality metrics on an entry
+func (p *Plugin) Quality(requestID string) (quality map[string]*visagoapi.PluginQualityResult, err error) {
+	quality = make(map[string]*visagoapi.PluginQualityResult)
+
+	if p.quality[requestID] == nil {
+		return quality, fmt.Errorf("quality request has not been made to quality")
+	}
+
+	for k, q := range p.quality[requestID] {
+		quality[k] = q
+	}
+
+	return
+}
+
+// ItemErrors returns the errors of the files that could
+// not be read.
+func (p *Plugin) ItemErrors(requestID string) (errs map[string]error, err error) {
+	errs = make(map[string]error)
+
+	if p.errors[requestID] == nil {
+		return errs, fmt.Errorf("request has not been made to quality")
+	}
+
+	for k, e := range p.errors[requestID] {
+		errs[k] = e
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.quality = make(map[string]map[string]*visagoapi.PluginQualityResult)
+	p.errors = make(map[string]map[string]error)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.quality {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	thresholds := make(map[string]float64)
+
+	for k, v := range defaultThresholds {
+		thresholds[k] = v
+
+		if o := p.options[k]; o != "" {
+			f, err := strconv.ParseFloat(o, 64)
+			if err != nil {
+				p.configured = false
+				return fmt.Errorf("invalid %s %q", k, o)
+			}
+
+			thresholds[k] = f
+		}
+	}
+
+	p.quality = make(map[string]map[string]*visagoapi.PluginQualityResult)
+	p.errors = make(map[string]map[string]error)
+
+	p.thresholds = thresholds
+	p.configured = true
+
+	return nil
+}
+
+// result applies the thresholds to the measured metrics.
+func (p *Plugin) result(m *metrics) *visagoapi.PluginQualityResult {
+	q := &visagoapi.PluginQualityResult{
+		Sharpness:         round(m.Sharpness, 2),
+		Brightness:        round(m.Brightness, 4),
+		Contrast:          round(m.Contrast, 4),
+		Noise:             round(m.Noise, 2),
+		ShadowClipping:    round(m.ShadowClipping, 4),
+		HighlightClipping: round(m.HighlightClipping, 4),
+	}
+
+	checks := []struct {
+		failure string
+		failed  bool
+	}{
+		{"blurry", m.Sharpness < p.thresholds["min_sharpness"]},
+		{"underexposed", m.Brightness < p.thresholds["min_brightness"]},
+		{"overexposed", m.Brightness > p.thresholds["max_brightness"]},
+		{"low_contrast", m.Contrast < p.thresholds["min_contrast"]},
+		{"noisy", m.Noise > p.thresholds["max_noise"]},
+		{"clipped_shadows", m.ShadowClipping > p.thresholds["max_shadow_clipping"]},
+		{"clipped_highlights", m.HighlightClipping > p.thresholds["max_highlight_clipping"]},
+	}
+
+	for _, c := range checks {
+		if c.failed {
+			q.Failures = append(q.Failures, c.failure)
+		}
+	}
+
+	q.Pass = len(q.Failures) == 0
+
+	return q
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Floor(v*p+0.5) / p
+}
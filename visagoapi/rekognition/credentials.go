@@ -0,0 +1,140 @@
+package rekognition
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	defaultProfile = "default"
+	defaultRegion  = "us-east-1"
+)
+
+// credentials are the AWS keys used to sign requests.
+type credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// loadCredentials reads credentials from the standard AWS
+// environment variables, falling back to the shared
+// credentials file for the current profile.
+func loadCredentials() *credentials {
+	creds := &credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds
+	}
+
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		file = awsFile("credentials")
+	}
+
+	section := readINI(file)[profile()]
+
+	return &credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+}
+
+// loadRegion reads the region from the environment,
+// falling back to the shared config file.
+func loadRegion() string {
+	for _, key := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(key); region != "" {
+			return region
+		}
+	}
+
+	file := os.Getenv("AWS_CONFIG_FILE")
+	if file == "" {
+		file = awsFile("config")
+	}
+
+	p := profile()
+
+	// The config file prefixes named profiles.
+	sectionName := p
+	if p != defaultProfile {
+		sectionName = "profile " + p
+	}
+
+	if region := readINI(file)[sectionName]["region"]; region != "" {
+		return region
+	}
+
+	return defaultRegion
+}
+
+func profile() string {
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+
+	return defaultProfile
+}
+
+func awsFile(name string) string {
+	h, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(h, ".aws", name)
+}
+
+// readINI parses the subset of INI used by the AWS shared files.
+func readINI(file string) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+
+	if file == "" {
+		return sections
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return sections
+	}
+	defer f.Close()
+
+	current := ""
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := sections[current]; !ok {
+				sections[current] = make(map[string]string)
+			}
+
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || current == "" {
+			continue
+		}
+
+		sections[current][strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return sections
+}
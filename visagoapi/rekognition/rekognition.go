@@ -0,0 +1,343 @@
+package rekognition
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	goimage "image"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	// Register decoders to read image dimensions.
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/nats-io/nuid"
+	"github.com/zquestz/visago/visagoapi"
+)
+
+func init() {
+	visagoapi.AddPluginFactory("rekognition", func() visagoapi.Plugin {
+		return &Plugin{}
+	})
+}
+
+// Plugin implements the Plugin interface and stores
+// configuration data needed by Amazon Rekognition.
+type Plugin struct {
+	configured     bool
+	creds          *credentials
+	region         string
+	endpoint       string
+	labelResponses map[string]map[string]*detectLabelsResponse
+	faceResponses  map[string]map[string]*detectFacesResponse
+	dimensions     map[string]map[string]goimage.Point
+}
+
+// Perform gathers metadata from Amazon Rekognition.
+func (p *Plugin) Perform(c *visagoapi.PluginConfig) (string, visagoapi.PluginResult, error) {
+	if p.configured == false {
+		return "", nil, fmt.Errorf("not configured")
+	}
+
+	if len(c.URLs) == 0 && len(c.Files) == 0 {
+		return "", nil, fmt.Errorf("must supply files/URLs")
+	}
+
+	client := &http.Client{}
+
+	requestID := nuid.Next()
+
+	p.labelResponses[requestID] = make(map[string]*detectLabelsResponse)
+	p.faceResponses[requestID] = make(map[string]*detectFacesResponse)
+	p.dimensions[requestID] = make(map[string]goimage.Point)
+
+	items := []string{}
+	items = append(items, c.URLs...)
+	items = append(items, c.Files...)
+
+	for _, item := range items {
+		// Rekognition only accepts image bytes or S3 objects,
+		// so URLs are downloaded first.
+		b, err := readItem(client, item)
+		if err != nil {
+			return "", nil, err
+		}
+
+		img := &image{Bytes: b}
+
+		if c.EnabledFeature(visagoapi.TagsFeature) {
+			req := &detectLabelsRequest{
+				Image:         img,
+				MaxLabels:     c.MaxResultsFor("rekognition", visagoapi.TagsFeature),
+				MinConfidence: c.TagScore * 100,
+			}
+
+			resp := &detectLabelsResponse{}
+			err = p.call(client, "DetectLabels", req, resp)
+			if err != nil {
+				return "", nil, err
+			}
+
+			p.labelResponses[requestID][item] = resp
+		}
+
+		if c.EnabledFeature(visagoapi.FacesFeature) {
+			cfg, _, err := goimage.DecodeConfig(bytes.NewReader(b))
+			if err != nil {
+				return "", nil, fmt.Errorf("failed to read dimensions of %s: %s", item, err)
+			}
+
+			p.dimensions[requestID][item] = goimage.Pt(cfg.Width, cfg.Height)
+
+			req := &detectFacesRequest{
+				Image:      img,
+				Attributes: []string{"ALL"},
+			}
+
+			resp := &detectFacesResponse{}
+			err = p.call(client, "DetectFaces", req, resp)
+			if err != nil {
+				return "", nil, err
+			}
+
+			p.faceResponses[requestID][item] = resp
+		}
+	}
+
+	return requestID, p, nil
+}
+
+// Tags returns the tags on an entry
+func (p *Plugin) Tags(requestID string, score float64) (tags map[string]map[string]*visagoapi.PluginTagResult, err error) {
+	tags = make(map[string]map[string]*visagoapi.PluginTagResult)
+
+	if p.labelResponses[requestID] == nil {
+		return tags, fmt.Errorf("tag request has not been made to rekognition")
+	}
+
+	for k, resp := range p.labelResponses[requestID] {
+		tags[k] = make(map[string]*visagoapi.PluginTagResult)
+
+		for _, l := range resp.Labels {
+			confidence := l.Confidence / 100
+
+			if confidence > score {
+				tag := &visagoapi.PluginTagResult{
+					Name:  l.Name,
+					Score: confidence,
+				}
+
+				tags[k][l.Name] = tag
+			}
+		}
+	}
+
+	return
+}
+
+// Colors returns the colors on an entry
+func (p *Plugin) Colors(requestID string) (colors map[string]map[string]*visagoapi.PluginColorResult, err error) {
+	colors = make(map[string]map[string]*visagoapi.PluginColorResult)
+
+	return
+}
+
+// Faces returns the faces on an entry
+func (p *Plugin) Faces(requestID string) (faces map[string][]*visagoapi.PluginFaceResult, err error) {
+	faces = make(map[string][]*visagoapi.PluginFaceResult)
+
+	if p.faceResponses[requestID] == nil {
+		return faces, fmt.Errorf("face request has not been made to rekognition")
+	}
+
+	for k, resp := range p.faceResponses[requestID] {
+		dim := p.dimensions[requestID][k]
+
+		for _, fd := range resp.FaceDetails {
+			face := &visagoapi.PluginFaceResult{
+				DetectionScore: fd.Confidence / 100,
+			}
+
+			if fd.BoundingBox != nil {
+				face.BoundingPoly = boundingPoly(fd.BoundingBox, dim)
+			}
+
+			for _, e := range fd.Emotions {
+				likelihood := emotionLikelihood(e.Confidence)
+
+				switch e.Type {
+				case "HAPPY":
+					face.JoyLikelihood = likelihood
+				case "SAD":
+					face.SorrowLikelihood = likelihood
+				case "ANGRY":
+					face.AngerLikelihood = likelihood
+				case "SURPRISED":
+					face.SurpriseLikelihood = likelihood
+				}
+			}
+
+			faces[k] = append(faces[k], face)
+		}
+	}
+
+	return
+}
+
+// Reset clears the cache of existing responses.
+func (p *Plugin) Reset() {
+	p.labelResponses = make(map[string]map[string]*detectLabelsResponse)
+	p.faceResponses = make(map[string]map[string]*detectFacesResponse)
+	p.dimensions = make(map[string]map[string]goimage.Point)
+}
+
+// RequestIDs returns a list of all cached response
+// requestIDs.
+func (p *Plugin) RequestIDs() ([]string, error) {
+	if p.configured == false {
+		return nil, fmt.Errorf("not configured")
+	}
+
+	keys := []string{}
+	for k := range p.labelResponses {
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// BatchLimits sends one item per batch. DetectLabels and
+// DetectFaces take a single image of up to 5MB per call, so
+// larger batches would only let one bad image fail the others.
+func (p *Plugin) BatchLimits() visagoapi.BatchLimits {
+	return visagoapi.BatchLimits{MaxItems: 1}
+}
+
+// Setup sets up the plugin for use. This should only
+// be called once per plugin.
+func (p *Plugin) Setup() error {
+	creds := loadCredentials()
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		p.configured = false
+		return fmt.Errorf("credentials not found")
+	}
+
+	p.region = loadRegion()
+
+	p.endpoint = os.Getenv("AWS_ENDPOINT_URL_REKOGNITION")
+	if p.endpoint == "" {
+		p.endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+
+	if p.endpoint == "" {
+		p.endpoint = fmt.Sprintf("https://rekognition.%s.amazonaws.com", p.region)
+	}
+
+	p.labelResponses = make(map[string]map[string]*detectLabelsResponse)
+	p.faceResponses = make(map[string]map[string]*detectFacesResponse)
+	p.dimensions = make(map[string]map[string]goimage.Point)
+
+	p.creds = creds
+	p.configured = true
+
+	return nil
+}
+
+// call sends a signed request for the given Rekognition action
+// and decodes the response into out.
+func (p *Plugin) call(client *http.Client, action string, in interface{}, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(p.endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "RekognitionService."+action)
+
+	signRequest(req, body, p.creds, p.region, time.Now())
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := errorResponse{}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Message != "" {
+			return fmt.Errorf("rekognition %s failed: %s", action, errResp.Message)
+		}
+
+		return fmt.Errorf("rekognition %s failed: %s", action, resp.Status)
+	}
+
+	return json.Unmarshal(respBody, out)
+}
+
+func readItem(client *http.Client, item string) ([]byte, error) {
+	if strings.HasPrefix(item, "http://") || strings.HasPrefix(item, "https://") {
+		resp, err := client.Get(item)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to fetch %s: %s", item, resp.Status)
+		}
+
+		return ioutil.ReadAll(resp.Body)
+	}
+
+	return ioutil.ReadFile(item)
+}
+
+// boundingPoly converts a bounding box, expressed as ratios
+// of the image size, into pixel vertices.
+func boundingPoly(bb *boundingBox, dim goimage.Point) *visagoapi.BoundingPoly {
+	left := int64(bb.Left * float64(dim.X))
+	top := int64(bb.Top * float64(dim.Y))
+	right := int64((bb.Left + bb.Width) * float64(dim.X))
+	bottom := int64((bb.Top + bb.Height) * float64(dim.Y))
+
+	return &visagoapi.BoundingPoly{
+		Vertices: []*visagoapi.Vertex{
+			{X: left, Y: top},
+			{X: right, Y: top},
+			{X: right, Y: bottom},
+			{X: left, Y: bottom},
+		},
+	}
+}
+
+// emotionLikelihood maps a confidence percentage onto
+// the likelihood values used by the other plugins.
+func emotionLikelihood(confidence float64) string {
+	switch {
+	case confidence >= 85:
+		return "VERY_LIKELY"
+	case confidence >= 60:
+		return "LIKELY"
+	case confidence >= 30:
+		return "POSSIBLE"
+	case confidence >= 10:
+		return "UNLIKELY"
+	default:
+		return "VERY_UNLIKELY"
+	}
+}
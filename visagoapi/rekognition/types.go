@@ -0,0 +1,52 @@
+package rekognition
+
+type image struct {
+	Bytes []byte `json:"Bytes"`
+}
+
+type detectLabelsRequest struct {
+	Image         *image  `json:"Image"`
+	MaxLabels     int64   `json:"MaxLabels,omitempty"`
+	MinConfidence float64 `json:"MinConfidence,omitempty"`
+}
+
+type detectLabelsResponse struct {
+	Labels []*label `json:"Labels"`
+}
+
+type label struct {
+	Name       string  `json:"Name"`
+	Confidence float64 `json:"Confidence"`
+}
+
+type detectFacesRequest struct {
+	Image      *image   `json:"Image"`
+	Attributes []string `json:"Attributes"`
+}
+
+type detectFacesResponse struct {
+	FaceDetails []*faceDetail `json:"FaceDetails"`
+}
+
+type faceDetail struct {
+	BoundingBox *boundingBox `json:"BoundingBox"`
+	Confidence  float64      `json:"Confidence"`
+	Emotions    []*emotion   `json:"Emotions"`
+}
+
+type boundingBox struct {
+	Width  float64 `json:"Width"`
+	Height float64 `json:"Height"`
+	Left   float64 `json:"Left"`
+	Top    float64 `json:"Top"`
+}
+
+type emotion struct {
+	Type       string  `json:"Type"`
+	Confidence float64 `json:"Confidence"`
+}
+
+type errorResponse struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,26 @@
+package visagoapi
+
+// Renderer formats the results returned by FetchResults.
+type Renderer interface {
+	Render(map[string]*Result) (string, error)
+}
+
+// RendererFunc adapts a function to the Renderer interface.
+type RendererFunc func(map[string]*Result) (string, error)
+
+// Render calls f(output).
+func (f RendererFunc) Render(output map[string]*Result) (string, error) {
+	return f(output)
+}
+
+var (
+	// TextRenderer renders the results as text.
+	TextRenderer Renderer = RendererFunc(func(output map[string]*Result) (string, error) {
+		return displayOutput(output, false), nil
+	})
+
+	// JSONRenderer renders the results as indented JSON.
+	JSONRenderer Renderer = RendererFunc(func(output map[string]*Result) (string, error) {
+		return displayOutput(output, true), nil
+	})
+)
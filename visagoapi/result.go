@@ -1,5 +1,7 @@
 package visagoapi
 
+import "sort"
+
 // Result is the struct passed back to the user.
 type Result struct {
 	Assets []*Asset `json:"assets,omitempty"`
@@ -8,14 +10,57 @@ type Result struct {
 
 // Asset represents each item fetched.
 type Asset struct {
-	Name   string                          `json:"name,omitempty"`
-	Tags   map[string][]*PluginTagResult   `json:"tags,omitempty"`
-	Colors map[string][]*PluginColorResult `json:"colors,omitempty"`
-	Faces  []*PluginFaceResult             `json:"faces,omitempty"`
-	Source string                          `json:"-"`
+	Name      string                          `json:"name,omitempty"`
+	Tags      map[string][]*PluginTagResult   `json:"tags,omitempty"`
+	Colors    map[string][]*PluginColorResult `json:"colors,omitempty"`
+	Faces     []*PluginFaceResult             `json:"faces,omitempty"`
+	Web       []*PluginWebResult              `json:"web,omitempty"`
+	CropHints []*PluginCropHintResult         `json:"crop_hints,omitempty"`
+	Objects   []*PluginObjectResult           `json:"objects,omitempty"`
+	Codes     []*PluginCodeResult             `json:"codes,omitempty"`
+	Captions  []*PluginCaptionResult          `json:"captions,omitempty"`
+	Metadata  []*PluginMetadataResult         `json:"metadata,omitempty"`
+	Quality   []*PluginQualityResult          `json:"quality,omitempty"`
+	Hashes    []*PluginHashResult             `json:"hashes,omitempty"`
+	Location  []*PluginLocationResult         `json:"location,omitempty"`
+	Source    string                          `json:"-"`
+}
+
+// TopTags returns the n best tags of the asset, sorted by score.
+// Tags reported by several sources are counted once, with their
+// highest score. Zero or less returns every tag.
+func (a *Asset) TopTags(n int) []*PluginTagResult {
+	tags := []*PluginTagResult{}
+
+	for _, results := range a.Tags {
+		var best *PluginTagResult
+		for _, t := range results {
+			if best == nil || t.Score > best.Score {
+				best = t
+			}
+		}
+
+		if best != nil {
+			tags = append(tags, best)
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Score != tags[j].Score {
+			return tags[i].Score > tags[j].Score
+		}
+
+		return tags[i].Name < tags[j].Name
+	})
+
+	if n > 0 && len(tags) > n {
+		tags = tags[:n]
+	}
+
+	return tags
 }
 
-func mergeAssets(assets []*Asset) []*Asset {
+func mergeAssets(assets []*Asset, geocoders []ReverseGeocoder) []*Asset {
 	mergedAssets := []*Asset{}
 
 	assetMap := make(map[string][]*Asset)
@@ -35,6 +80,15 @@ func mergeAssets(assets []*Asset) []*Asset {
 		mergedAsset.Tags = make(map[string][]*PluginTagResult)
 		mergedAsset.Faces = []*PluginFaceResult{}
 		mergedAsset.Colors = make(map[string][]*PluginColorResult)
+		mergedAsset.Web = []*PluginWebResult{}
+		mergedAsset.CropHints = []*PluginCropHintResult{}
+		mergedAsset.Objects = []*PluginObjectResult{}
+		mergedAsset.Codes = []*PluginCodeResult{}
+		mergedAsset.Captions = []*PluginCaptionResult{}
+		mergedAsset.Metadata = []*PluginMetadataResult{}
+		mergedAsset.Quality = []*PluginQualityResult{}
+		mergedAsset.Hashes = []*PluginHashResult{}
+		mergedAsset.Location = []*PluginLocationResult{}
 
 		for _, a := range v {
 			for tk := range a.Tags {
@@ -53,6 +107,7 @@ func mergeAssets(assets []*Asset) []*Asset {
 				for _, c := range a.Colors[ck] {
 					nc := &PluginColorResult{
 						Source:        a.Source,
+						Name:          c.Name,
 						Score:         c.Score,
 						Alpha:         c.Alpha,
 						Hex:           c.Hex,
@@ -82,10 +137,123 @@ func mergeAssets(assets []*Asset) []*Asset {
 
 				mergedAsset.Faces = append(mergedAsset.Faces, nf)
 			}
+
+			for _, w := range a.Web {
+				nw := &PluginWebResult{
+					Source:                  a.Source,
+					BestGuessLabels:         w.BestGuessLabels,
+					Entities:                w.Entities,
+					FullMatchingImages:      w.FullMatchingImages,
+					PartialMatchingImages:   w.PartialMatchingImages,
+					VisuallySimilarImages:   w.VisuallySimilarImages,
+					PagesWithMatchingImages: w.PagesWithMatchingImages,
+				}
+
+				mergedAsset.Web = append(mergedAsset.Web, nw)
+			}
+
+			for _, ch := range a.CropHints {
+				nch := &PluginCropHintResult{
+					Source:             a.Source,
+					BoundingPoly:       ch.BoundingPoly,
+					AspectRatio:        ch.AspectRatio,
+					Confidence:         ch.Confidence,
+					ImportanceFraction: ch.ImportanceFraction,
+				}
+
+				mergedAsset.CropHints = append(mergedAsset.CropHints, nch)
+			}
+
+			for _, o := range a.Objects {
+				no := &PluginObjectResult{
+					Source:       a.Source,
+					Name:         o.Name,
+					Score:        o.Score,
+					BoundingPoly: o.BoundingPoly,
+				}
+
+				mergedAsset.Objects = append(mergedAsset.Objects, no)
+			}
+
+			for _, c := range a.Codes {
+				nc := &PluginCodeResult{
+					Source:       a.Source,
+					Symbology:    c.Symbology,
+					Payload:      c.Payload,
+					BoundingPoly: c.BoundingPoly,
+				}
+
+				mergedAsset.Codes = append(mergedAsset.Codes, nc)
+			}
+
+			for _, c := range a.Captions {
+				nc := &PluginCaptionResult{
+					Source: a.Source,
+					Text:   c.Text,
+					Score:  c.Score,
+				}
+
+				mergedAsset.Captions = append(mergedAsset.Captions, nc)
+			}
+
+			for _, m := range a.Metadata {
+				nm := *m
+				nm.Source = a.Source
+
+				mergedAsset.Metadata = append(mergedAsset.Metadata, &nm)
+			}
+
+			for _, q := range a.Quality {
+				nq := *q
+				nq.Source = a.Source
+
+				mergedAsset.Quality = append(mergedAsset.Quality, &nq)
+			}
+
+			for _, h := range a.Hashes {
+				nh := *h
+				nh.Source = a.Source
+
+				mergedAsset.Hashes = append(mergedAsset.Hashes, &nh)
+			}
+
+			for _, l := range a.Location {
+				nl := *l
+				nl.Source = a.Source
+
+				mergedAsset.Location = append(mergedAsset.Location, &nl)
+			}
 		}
 
+		geocodeLocations(mergedAsset.Location, geocoders)
+
 		mergedAssets = append(mergedAssets, &mergedAsset)
 	}
 
 	return mergedAssets
 }
+
+// geocodeLocations adds the nearest place to locations that only
+// have coordinates, such as landmarks. The first geocoder with a
+// match wins.
+func geocodeLocations(locations []*PluginLocationResult, geocoders []ReverseGeocoder) {
+	for _, l := range locations {
+		if l.CountryCode != "" {
+			continue
+		}
+
+		for _, g := range geocoders {
+			place, ok := g.ReverseGeocode(l.Latitude, l.Longitude)
+			if !ok {
+				continue
+			}
+
+			l.City = place.City
+			l.Region = place.Region
+			l.CountryCode = place.CountryCode
+			l.Distance = place.Distance
+
+			break
+		}
+	}
+}
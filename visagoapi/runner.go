@@ -2,10 +2,11 @@ package visagoapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"sort"
-	"sync"
+	"strings"
 )
 
 const (
@@ -14,128 +15,128 @@ const (
 )
 
 type runner struct {
-	Name      string
-	TagData   map[string]map[string]*PluginTagResult
-	FaceData  map[string][]*PluginFaceResult
-	ColorData map[string]map[string]*PluginColorResult
-	Errors    []error
-	Items     []string
+	Name         string
+	TagData      map[string]map[string]*PluginTagResult
+	FaceData     map[string][]*PluginFaceResult
+	ColorData    map[string]map[string]*PluginColorResult
+	WebData      map[string]*PluginWebResult
+	CropData     map[string][]*PluginCropHintResult
+	ObjectData   map[string][]*PluginObjectResult
+	CodeData     map[string][]*PluginCodeResult
+	CaptionData  map[string]*PluginCaptionResult
+	MetadataData map[string]*PluginMetadataResult
+	QualityData  map[string]*PluginQualityResult
+	HashData     map[string]*PluginHashResult
+	LocationData map[string][]*PluginLocationResult
+	Geocoder     ReverseGeocoder
+	Errors       []error
+	ItemErrors   map[string]error
 }
 
 // RunPlugins runs all the plugins with the provided pluginConfig.
 // Output is directed at stdout. Not intended for API use.
 func RunPlugins(pluginConfig *PluginConfig, jsonOutput bool) (string, error) {
-	wg := &sync.WaitGroup{}
-	dwg := &sync.WaitGroup{}
-
-	outputChan := make(chan string)
-	defer close(outputChan)
-
-	runChan := make(chan *runner)
-	defer close(runChan)
-
-	finishedChan := make(chan bool)
-	defer close(finishedChan)
-
-	dwg.Add(1)
-	go processOutput(dwg, outputChan, runChan, finishedChan, jsonOutput)
-
-	runnerItems := []string{}
-	runnerItems = append(runnerItems, pluginConfig.URLs...)
-	runnerItems = append(runnerItems, pluginConfig.Files...)
-
-	for _, name := range PluginNames() {
-		wg.Add(1)
-		r := runner{
-			Name:  name,
-			Items: runnerItems,
-		}
-		go r.run(name, pluginConfig, wg, runChan)
+	output, err := defaultClient.FetchResults(pluginConfig)
+	if err != nil {
+		return "", err
 	}
 
-	// Wait for plugins to finish.
-	wg.Wait()
-
-	finishedChan <- true
-
-	output := <-outputChan
-
-	// Wait for display goroutine to end.
-	dwg.Wait()
+	if jsonOutput {
+		return JSONRenderer.Render(output)
+	}
 
-	return output, nil
+	return TextRenderer.Render(output)
 }
 
-func processOutput(wg *sync.WaitGroup, outputChan chan<- string, runChan <-chan *runner, finishedChan <-chan bool, jsonOutput bool) {
-	defer wg.Done()
+// FetchResults runs all the plugins with the provided pluginConfig
+// and returns the results keyed by plugin name. The merged results
+// of every plugin are stored under the "all" key.
+func FetchResults(pluginConfig *PluginConfig) (map[string]*Result, error) {
+	return defaultClient.FetchResults(pluginConfig)
+}
 
-	runners := []*runner{}
+// FetchResults runs the plugins of the client with the provided
+// pluginConfig and returns the results keyed by plugin name. The
+// merged results of every plugin are stored under the "all" key.
+func (c *Client) FetchResults(pluginConfig *PluginConfig) (map[string]*Result, error) {
+	events := []*Event{}
 
-Loop:
-	for {
-		select {
-		case runner := <-runChan:
-			runners = append(runners, runner)
-		case <-finishedChan:
-			break Loop
-		}
-	}
+	c.stream(context.Background(), pluginConfig, func(e *Event) {
+		events = append(events, e)
+	})
 
-	outputChan <- displayOutput(buildOutput(runners), jsonOutput)
+	return buildOutput(events), nil
+}
 
-	return
+// CollectResults collects the events of a run, as sent by Stream,
+// into the results FetchResults returns.
+func CollectResults(events []*Event) map[string]*Result {
+	return buildOutput(events)
 }
 
-func buildOutput(runners []*runner) map[string]*Result {
+// buildOutput collects the events of a run into results keyed by
+// plugin name, and merges every asset under the "all" key.
+func buildOutput(events []*Event) map[string]*Result {
 	output := make(map[string]*Result)
 
 	output[allKey] = &Result{}
-	allAssets := []*Asset{}
 
-	for _, r := range runners {
-		if _, ok := output[r.Name]; !ok {
-			output[r.Name] = &Result{}
-		}
+	assets := make(map[string][]*Event)
+	geocoders := make(map[string]ReverseGeocoder)
 
-		if len(r.Errors) > 0 {
-			output[r.Name].Errors = []string{}
-			for _, e := range r.Errors {
-				output[r.Name].Errors = append(output[r.Name].Errors, e.Error())
-				output[allKey].Errors = append(output[allKey].Errors, e.Error())
-			}
+	for _, e := range events {
+		if e.Plugin == "" {
+			continue
 		}
 
-		for _, item := range r.Items {
-			tagMap := make(map[string][]*PluginTagResult)
-			colorMap := make(map[string][]*PluginColorResult)
+		if _, ok := output[e.Plugin]; !ok {
+			output[e.Plugin] = &Result{}
+		}
 
-			for _, tagInfo := range r.TagData[item] {
-				tagMap[tagInfo.Name] = append(tagMap[tagInfo.Name], tagInfo)
+		switch e.Type {
+		case ItemEvent:
+			if e.Asset != nil {
+				assets[e.Plugin] = append(assets[e.Plugin], e)
 			}
-
-			for _, colorInfo := range r.ColorData[item] {
-				colorMap[colorInfo.Hex] = append(colorMap[colorInfo.Hex], colorInfo)
+		case ErrorEvent:
+			output[e.Plugin].Errors = append(output[e.Plugin].Errors, e.Error)
+			output[allKey].Errors = append(output[allKey].Errors, e.Error)
+		case PluginEvent:
+			if e.geocoder != nil {
+				geocoders[e.Plugin] = e.geocoder
 			}
+		}
+	}
 
-			// Only include the asset if we have data.
-			if len(tagMap) > 0 || len(colorMap) > 0 || len(r.FaceData[item]) > 0 {
-				asset := Asset{
-					Name:   item,
-					Tags:   tagMap,
-					Faces:  r.FaceData[item],
-					Colors: colorMap,
-					Source: r.Name,
-				}
+	names := []string{}
+	for name := range output {
+		if name != allKey {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
 
-				output[r.Name].Assets = append(output[r.Name].Assets, &asset)
+	allAssets := []*Asset{}
+	allGeocoders := []ReverseGeocoder{}
+
+	for _, name := range names {
+		// Batches finish in any order, keep the input order.
+		items := assets[name]
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].index < items[j].index
+		})
+
+		for _, e := range items {
+			output[name].Assets = append(output[name].Assets, e.Asset)
+			allAssets = append(allAssets, e.Asset)
+		}
 
-				allAssets = append(allAssets, &asset)
-			}
+		if geocoders[name] != nil {
+			allGeocoders = append(allGeocoders, geocoders[name])
 		}
 	}
 
-	mergedAssets := mergeAssets(allAssets)
-	output[allKey].Assets = mergedAssets
+	output[allKey].Assets = mergeAssets(allAssets, allGeocoders)
 
 	return output
 }
@@ -169,6 +170,14 @@ func displayOutput(output map[string]*Result, jsonOutput bool) string {
 			for _, asset := range result.Assets {
 				outputBuf.WriteString(fmt.Sprintf("Asset: %s\n", asset.Name))
 
+				for _, caption := range asset.Captions {
+					outputBuf.WriteString(fmt.Sprintf("Caption: %s\n", caption.Text))
+				}
+
+				for _, metadata := range asset.Metadata {
+					outputBuf.WriteString(displayMetadata(metadata))
+				}
+
 				tagKeys := []string{}
 				for k := range asset.Tags {
 					tagKeys = append(tagKeys, k)
@@ -192,6 +201,34 @@ func displayOutput(output map[string]*Result, jsonOutput bool) string {
 				if len(asset.Faces) > 0 {
 					outputBuf.WriteString(fmt.Sprintf("Faces: %d\n", len(asset.Faces)))
 				}
+
+				for _, web := range asset.Web {
+					outputBuf.WriteString(displayWeb(web))
+				}
+
+				if len(asset.CropHints) > 0 {
+					outputBuf.WriteString(fmt.Sprintf("Crop Hints: %d\n", len(asset.CropHints)))
+				}
+
+				if len(asset.Objects) > 0 {
+					outputBuf.WriteString(fmt.Sprintf("Objects: %d\n", len(asset.Objects)))
+				}
+
+				for _, quality := range asset.Quality {
+					outputBuf.WriteString(displayQuality(quality))
+				}
+
+				for _, hash := range asset.Hashes {
+					outputBuf.WriteString(fmt.Sprintf("Hashes: ahash %s dhash %s phash %s\n", hash.AHash, hash.DHash, hash.PHash))
+				}
+
+				for _, location := range asset.Location {
+					outputBuf.WriteString(displayLocation(location))
+				}
+
+				for _, code := range asset.Codes {
+					outputBuf.WriteString(fmt.Sprintf("Code: %s %s\n", code.Symbology, code.Payload))
+				}
 			}
 
 			for _, err := range output[k].Errors {
@@ -205,23 +242,189 @@ func displayOutput(output map[string]*Result, jsonOutput bool) string {
 	return outputBuf.String()
 }
 
-func (r *runner) run(name string, pluginConfig *PluginConfig, wg *sync.WaitGroup, runChan chan<- *runner) {
-	defer wg.Done()
+func displayLocation(l *PluginLocationResult) string {
+	place := []string{}
+	for _, p := range []string{l.Landmark, l.City, l.Region, l.CountryCode} {
+		if p != "" {
+			place = append(place, p)
+		}
+	}
 
-	defer func() { runChan <- r }()
+	return fmt.Sprintf("Location: %s (%f, %f)\n", strings.Join(place, ", "), l.Latitude, l.Longitude)
+}
 
-	err := Plugins[name].Setup()
+func displayQuality(q *PluginQualityResult) string {
+	status := "pass"
+	if !q.Pass {
+		status = fmt.Sprintf("fail %v", q.Failures)
+	}
+
+	return fmt.Sprintf("Quality: %s (sharpness %.1f, brightness %.2f, contrast %.2f, noise %.1f)\n",
+		status, q.Sharpness, q.Brightness, q.Contrast, q.Noise)
+}
+
+func displayMetadata(m *PluginMetadataResult) string {
+	var metadataBuf bytes.Buffer
+
+	metadataBuf.WriteString(fmt.Sprintf("Metadata: %s %dx%d %d bytes\n", m.Format, m.Width, m.Height, m.Size))
+
+	// Many cameras repeat the make in the model name.
+	camera := m.CameraModel
+	if !strings.HasPrefix(camera, m.CameraMake) {
+		camera = strings.TrimSpace(fmt.Sprintf("%s %s", m.CameraMake, m.CameraModel))
+	}
+	if camera != "" {
+		metadataBuf.WriteString(fmt.Sprintf("Camera: %s\n", camera))
+	}
+
+	lens := strings.TrimSpace(fmt.Sprintf("%s %s", m.LensMake, m.LensModel))
+	if lens != "" {
+		metadataBuf.WriteString(fmt.Sprintf("Lens: %s\n", lens))
+	}
+
+	if m.CaptureTime != "" {
+		metadataBuf.WriteString(fmt.Sprintf("Captured: %s\n", m.CaptureTime))
+	}
+
+	if m.GPS != nil {
+		metadataBuf.WriteString(fmt.Sprintf("GPS: %f, %f\n", m.GPS.Latitude, m.GPS.Longitude))
+	}
+
+	return metadataBuf.String()
+}
+
+func displayWeb(web *PluginWebResult) string {
+	var webBuf bytes.Buffer
+
+	if len(web.BestGuessLabels) > 0 {
+		webBuf.WriteString(fmt.Sprintf("Web Labels: %v\n", web.BestGuessLabels))
+	}
+
+	if len(web.Entities) > 0 {
+		entities := []string{}
+		for _, e := range web.Entities {
+			if e.Description != "" {
+				entities = append(entities, e.Description)
+			}
+		}
+
+		webBuf.WriteString(fmt.Sprintf("Web Entities: %v\n", entities))
+	}
+
+	matches := len(web.FullMatchingImages) + len(web.PartialMatchingImages)
+	if matches > 0 {
+		webBuf.WriteString(fmt.Sprintf("Web Matching Images: %d\n", matches))
+	}
+
+	for _, page := range web.PagesWithMatchingImages {
+		webBuf.WriteString(fmt.Sprintf("Web Page: %s\n", page.URL))
+	}
+
+	return webBuf.String()
+}
+
+// asset returns the results of the runner for item, or
+// nil when the plugin has no data for it.
+func (r *runner) asset(item string) *Asset {
+	tagMap := make(map[string][]*PluginTagResult)
+	colorMap := make(map[string][]*PluginColorResult)
+
+	for _, tagInfo := range r.TagData[item] {
+		tagMap[tagInfo.Name] = append(tagMap[tagInfo.Name], tagInfo)
+	}
+
+	for _, colorInfo := range r.ColorData[item] {
+		colorMap[colorInfo.Hex] = append(colorMap[colorInfo.Hex], colorInfo)
+	}
+
+	webList := []*PluginWebResult{}
+	if r.WebData[item] != nil {
+		webList = append(webList, r.WebData[item])
+	}
+
+	captionList := []*PluginCaptionResult{}
+	if r.CaptionData[item] != nil {
+		captionList = append(captionList, r.CaptionData[item])
+	}
+
+	metadataList := []*PluginMetadataResult{}
+	if r.MetadataData[item] != nil {
+		metadataList = append(metadataList, r.MetadataData[item])
+	}
+
+	qualityList := []*PluginQualityResult{}
+	if r.QualityData[item] != nil {
+		qualityList = append(qualityList, r.QualityData[item])
+	}
+
+	hashList := []*PluginHashResult{}
+	if r.HashData[item] != nil {
+		hashList = append(hashList, r.HashData[item])
+	}
+
+	// Only include the asset if we have data.
+	if len(tagMap) == 0 && len(colorMap) == 0 && len(r.FaceData[item]) == 0 && len(webList) == 0 &&
+		len(r.CropData[item]) == 0 && len(r.ObjectData[item]) == 0 && len(r.CodeData[item]) == 0 && len(captionList) == 0 && len(metadataList) == 0 &&
+		len(qualityList) == 0 && len(hashList) == 0 && len(r.LocationData[item]) == 0 {
+		return nil
+	}
+
+	return &Asset{
+		Name:      item,
+		Tags:      tagMap,
+		Faces:     r.FaceData[item],
+		Colors:    colorMap,
+		Web:       webList,
+		CropHints: r.CropData[item],
+		Objects:   r.ObjectData[item],
+		Codes:     r.CodeData[item],
+		Captions:  captionList,
+		Metadata:  metadataList,
+		Quality:   qualityList,
+		Hashes:    hashList,
+		Location:  r.LocationData[item],
+		Source:    r.Name,
+	}
+}
+
+func (r *runner) run(name string, factory PluginFactory, pluginConfig *PluginConfig) {
+	p := factory()
+
+	s, shared := p.(*sharedPlugin)
+	if shared {
+		s.Lock()
+		defer s.Unlock()
+
+		p = s.Plugin
+	}
+
+	if plugin, ok := p.(ConfigurablePlugin); ok {
+		plugin.Configure(pluginConfig.Options[name])
+	}
+
+	err := p.Setup()
 	if err != nil {
 		r.Errors = append(r.Errors, err)
 		return
 	}
 
-	requestID, pluginResponse, err := Plugins[name].Perform(pluginConfig)
+	requestID, pluginResponse, err := p.Perform(pluginConfig)
 	if err != nil {
 		r.Errors = append(r.Errors, err)
 		return
 	}
 
+	errorsResponse, ok := pluginResponse.(ItemErrorsPluginResult)
+	if ok {
+		itemErrors, err := errorsResponse.ItemErrors(requestID)
+		if err != nil {
+			r.Errors = append(r.Errors, err)
+			return
+		}
+
+		r.ItemErrors = itemErrors
+	}
+
 	if pluginConfig.EnabledFeature(TagsFeature) {
 		tagData, err := pluginResponse.Tags(requestID, pluginConfig.TagScore)
 		if err != nil {
@@ -252,5 +455,129 @@ func (r *runner) run(name string, pluginConfig *PluginConfig, wg *sync.WaitGroup
 		r.FaceData = faceData
 	}
 
+	if pluginConfig.EnabledFeature(WebFeature) {
+		webResponse, ok := pluginResponse.(WebPluginResult)
+		if ok {
+			webData, err := webResponse.Web(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.WebData = webData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(CropHintsFeature) {
+		cropResponse, ok := pluginResponse.(CropHintsPluginResult)
+		if ok {
+			cropData, err := cropResponse.CropHints(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.CropData = cropData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(ObjectsFeature) {
+		objectsResponse, ok := pluginResponse.(ObjectsPluginResult)
+		if ok {
+			objectData, err := objectsResponse.Objects(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.ObjectData = objectData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(CodesFeature) {
+		codesResponse, ok := pluginResponse.(CodesPluginResult)
+		if ok {
+			codeData, err := codesResponse.Codes(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.CodeData = codeData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(CaptionsFeature) {
+		captionResponse, ok := pluginResponse.(CaptionsPluginResult)
+		if ok {
+			captionData, err := captionResponse.Captions(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.CaptionData = captionData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(QualityFeature) {
+		qualityResponse, ok := pluginResponse.(QualityPluginResult)
+		if ok {
+			qualityData, err := qualityResponse.Quality(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.QualityData = qualityData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(LocationFeature) {
+		locationResponse, ok := pluginResponse.(LocationPluginResult)
+		if ok {
+			locationData, err := locationResponse.Location(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.LocationData = locationData
+		}
+
+		// Shared instances may be set up again by another run
+		// before the results are merged.
+		geocoder, ok := p.(ReverseGeocoder)
+		if ok && !shared {
+			r.Geocoder = geocoder
+		}
+	}
+
+	if pluginConfig.EnabledFeature(HashesFeature) {
+		hashesResponse, ok := pluginResponse.(HashesPluginResult)
+		if ok {
+			hashData, err := hashesResponse.Hashes(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.HashData = hashData
+		}
+	}
+
+	if pluginConfig.EnabledFeature(MetadataFeature) {
+		metadataResponse, ok := pluginResponse.(MetadataPluginResult)
+		if ok {
+			metadataData, err := metadataResponse.Metadata(requestID)
+			if err != nil {
+				r.Errors = append(r.Errors, err)
+				return
+			}
+
+			r.MetadataData = metadataData
+		}
+	}
+
 	return
 }
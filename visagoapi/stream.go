@@ -0,0 +1,230 @@
+package visagoapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	// ItemEvent is sent when a plugin has finished an item.
+	// Asset is nil when the plugin has no data for the item.
+	ItemEvent EventType = "item"
+
+	// ErrorEvent is sent when a batch of a plugin fails, or
+	// when a plugin fails on a single item. Items lists the
+	// failed items.
+	ErrorEvent EventType = "error"
+
+	// PluginEvent is sent when a plugin has finished every item.
+	PluginEvent EventType = "plugin"
+
+	// DoneEvent is the last event, sent when every plugin
+	// has finished.
+	DoneEvent EventType = "done"
+)
+
+// Event reports the progress of a run as each plugin
+// finishes each item.
+type Event struct {
+	Type   EventType `json:"type"`
+	Plugin string    `json:"plugin,omitempty"`
+	Item   string    `json:"item,omitempty"`
+	Items  []string  `json:"items,omitempty"`
+	Asset  *Asset    `json:"asset,omitempty"`
+	Error  string    `json:"error,omitempty"`
+
+	index    int
+	geocoder ReverseGeocoder
+}
+
+// Stream runs all the plugins with the provided pluginConfig
+// and sends the events of the run. See Client.Stream.
+func Stream(ctx context.Context, pluginConfig *PluginConfig) <-chan *Event {
+	return defaultClient.Stream(ctx, pluginConfig)
+}
+
+// Stream runs the plugins of the client in the background and
+// sends an event as soon as each plugin finishes each item. The
+// channel is closed after the DoneEvent. Cancel ctx to stop
+// reading early: no more batches are started, the events of
+// running batches are dropped and the channel is closed once
+// they return.
+func (c *Client) Stream(ctx context.Context, pluginConfig *PluginConfig) <-chan *Event {
+	events := make(chan *Event)
+
+	go func() {
+		defer close(events)
+
+		c.stream(ctx, pluginConfig, func(e *Event) {
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return events
+}
+
+// stream runs the plugins of the client and calls emit with
+// each event. emit is never called concurrently. No batches
+// are started after ctx is done, and the DoneEvent is not sent.
+func (c *Client) stream(ctx context.Context, pluginConfig *PluginConfig, emit func(*Event)) {
+	pluginConfig = c.withDefaults(pluginConfig)
+
+	concurrency := pluginConfig.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	d := &dispatcher{
+		emit:  emit,
+		index: make(map[string]int),
+	}
+
+	for _, item := range append(append([]string{}, pluginConfig.URLs...), pluginConfig.Files...) {
+		if _, ok := d.index[item]; !ok {
+			d.index[item] = len(d.index)
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	jobs := make(chan *job)
+
+	for i := 0; i < concurrency; i++ {
+		go d.worker(wg, jobs)
+	}
+
+dispatch:
+	for _, name := range c.PluginNames() {
+		factory := c.factory(name)
+		batches := splitBatches(pluginConfig, batchLimits(factory))
+
+		progress := &pluginProgress{
+			name:    name,
+			pending: len(batches),
+		}
+
+		for _, b := range batches {
+			j := &job{
+				progress:     progress,
+				factory:      factory,
+				batch:        b,
+				pluginConfig: b.config(pluginConfig),
+			}
+
+			wg.Add(1)
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				wg.Done()
+				break dispatch
+			}
+		}
+	}
+
+	close(jobs)
+
+	// Wait for plugins to finish.
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	emit(&Event{Type: DoneEvent})
+}
+
+// pluginProgress tracks the batches of a plugin that have
+// not finished yet.
+type pluginProgress struct {
+	name     string
+	pending  int
+	geocoder ReverseGeocoder
+}
+
+// job runs one batch of a plugin.
+type job struct {
+	progress     *pluginProgress
+	factory      PluginFactory
+	batch        *batch
+	pluginConfig *PluginConfig
+}
+
+// dispatcher sends the events of finished batches.
+type dispatcher struct {
+	mu    sync.Mutex
+	emit  func(*Event)
+	index map[string]int
+}
+
+// worker runs jobs until the channel is closed.
+func (d *dispatcher) worker(wg *sync.WaitGroup, jobs <-chan *job) {
+	for j := range jobs {
+		r := &runner{Name: j.progress.name}
+		r.run(j.progress.name, j.factory, j.pluginConfig)
+
+		d.finish(j, r)
+		wg.Done()
+	}
+}
+
+// finish sends the events of a finished batch, followed by a
+// PluginEvent when it was the last batch of its plugin.
+func (d *dispatcher) finish(j *job, r *runner) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := j.progress.name
+
+	items := []string{}
+	items = append(items, j.batch.URLs...)
+	items = append(items, j.batch.Files...)
+
+	for _, err := range r.Errors {
+		d.emit(&Event{
+			Type:   ErrorEvent,
+			Plugin: name,
+			Items:  items,
+			Error:  err.Error(),
+		})
+	}
+
+	for _, item := range items {
+		if err := r.ItemErrors[item]; err != nil {
+			d.emit(&Event{
+				Type:   ErrorEvent,
+				Plugin: name,
+				Items:  []string{item},
+				Error:  fmt.Sprintf("%s: %s", item, err),
+			})
+		}
+	}
+
+	for _, item := range items {
+		d.emit(&Event{
+			Type:   ItemEvent,
+			Plugin: name,
+			Item:   item,
+			Asset:  r.asset(item),
+			index:  d.index[item],
+		})
+	}
+
+	if r.Geocoder != nil {
+		j.progress.geocoder = r.Geocoder
+	}
+
+	j.progress.pending--
+	if j.progress.pending == 0 {
+		d.emit(&Event{
+			Type:     PluginEvent,
+			Plugin:   name,
+			geocoder: j.progress.geocoder,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package visagoapi
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// startedEchoPlugin is an echoPlugin that runs one item per batch
+// and reports each batch it starts.
+type startedEchoPlugin struct {
+	echoPlugin
+	started chan<- bool
+}
+
+func (p *startedEchoPlugin) Perform(c *PluginConfig) (string, PluginResult, error) {
+	p.started <- true
+
+	return p.echoPlugin.Perform(c)
+}
+
+func (p *startedEchoPlugin) BatchLimits() BatchLimits {
+	return BatchLimits{MaxItems: 1}
+}
+
+// TestStreamCancel stops reading a stream while a batch is still
+// running, and checks that every goroutine of the run returns.
+func TestStreamCancel(t *testing.T) {
+	const items = 100
+
+	before := runtime.NumGoroutine()
+
+	started := make(chan bool, items)
+	c := NewClient(WithPluginFactories(map[string]PluginFactory{
+		"echo": func() Plugin { return &startedEchoPlugin{started: started} },
+	}))
+
+	urls := []string{}
+	for i := 0; i < items; i++ {
+		urls = append(urls, fmt.Sprintf("https://example.com/%d.jpg", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := c.Stream(ctx, &PluginConfig{
+		URLs:        urls,
+		Features:    []string{TagsFeature},
+		Concurrency: 4,
+	})
+
+	if e := <-events; e == nil || e.Type != ItemEvent {
+		t.Fatalf("first event is %v, want an item", e)
+	}
+
+	// The events of a second batch are never read.
+	<-started
+	<-started
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left after cancel, want %d", runtime.NumGoroutine(), before)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, ok := <-events; ok {
+		t.Fatal("events still open after cancel")
+	}
+}
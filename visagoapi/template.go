@@ -0,0 +1,174 @@
+package visagoapi
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateFuncs are the helper functions available to templates
+// created with NewTemplate.
+//
+//	topTags n asset   the n best merged tags of an asset
+//	join sep list     joins names, hexes or strings with sep
+//	percent score     formats a 0-1 score as a percentage
+//	colorName color   names a color result or hex value
+var TemplateFuncs = template.FuncMap{
+	"topTags":   templateTopTags,
+	"join":      templateJoin,
+	"percent":   templatePercent,
+	"colorName": templateColorName,
+}
+
+// namedColors are the colors returned by colorName.
+var namedColors = []struct {
+	Name    string
+	R, G, B float64
+}{
+	{"black", 0, 0, 0},
+	{"white", 255, 255, 255},
+	{"gray", 128, 128, 128},
+	{"silver", 192, 192, 192},
+	{"red", 255, 0, 0},
+	{"maroon", 128, 0, 0},
+	{"orange", 255, 165, 0},
+	{"brown", 165, 42, 42},
+	{"yellow", 255, 255, 0},
+	{"olive", 128, 128, 0},
+	{"green", 0, 128, 0},
+	{"lime", 0, 255, 0},
+	{"teal", 0, 128, 128},
+	{"cyan", 0, 255, 255},
+	{"blue", 0, 0, 255},
+	{"navy", 0, 0, 128},
+	{"purple", 128, 0, 128},
+	{"magenta", 255, 0, 255},
+	{"pink", 255, 192, 203},
+}
+
+// NewTemplate parses a template with TemplateFuncs.
+func NewTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(TemplateFuncs).Parse(text)
+}
+
+// TemplateRenderer returns a renderer executing tmpl once with
+// the results keyed by plugin name, as returned by FetchResults.
+func TemplateRenderer(tmpl *template.Template) Renderer {
+	return RendererFunc(func(output map[string]*Result) (string, error) {
+		var buf bytes.Buffer
+
+		err := tmpl.Execute(&buf, output)
+		if err != nil {
+			return "", err
+		}
+
+		return buf.String(), nil
+	})
+}
+
+// AssetTemplateRenderer returns a renderer executing tmpl for each
+// merged asset, sorted by name. Each asset ends with a newline.
+func AssetTemplateRenderer(tmpl *template.Template) Renderer {
+	return RendererFunc(func(output map[string]*Result) (string, error) {
+		var buf bytes.Buffer
+
+		all := output[allKey]
+		if all == nil {
+			return "", nil
+		}
+
+		assets := append([]*Asset{}, all.Assets...)
+		sort.Slice(assets, func(i, j int) bool {
+			return assets[i].Name < assets[j].Name
+		})
+
+		for _, asset := range assets {
+			err := tmpl.Execute(&buf, asset)
+			if err != nil {
+				return "", err
+			}
+
+			buf.WriteString("\n")
+		}
+
+		return buf.String(), nil
+	})
+}
+
+func templateTopTags(n int, asset *Asset) []*PluginTagResult {
+	if asset == nil {
+		return []*PluginTagResult{}
+	}
+
+	return asset.TopTags(n)
+}
+
+func templateJoin(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: cannot join %T", list)
+	}
+
+	items := []string{}
+	for i := 0; i < v.Len(); i++ {
+		switch e := v.Index(i).Interface().(type) {
+		case *PluginTagResult:
+			items = append(items, e.Name)
+		case *PluginColorResult:
+			items = append(items, e.Hex)
+		case string:
+			items = append(items, e)
+		default:
+			items = append(items, fmt.Sprint(e))
+		}
+	}
+
+	return strings.Join(items, sep), nil
+}
+
+func templatePercent(score float64) string {
+	return strconv.FormatFloat(score*100, 'f', 1, 64) + "%"
+}
+
+func templateColorName(color interface{}) (string, error) {
+	var r, g, b float64
+
+	switch c := color.(type) {
+	case *PluginColorResult:
+		if c.Name != "" {
+			return c.Name, nil
+		}
+
+		if c.Hex != "" {
+			return templateColorName(c.Hex)
+		}
+
+		r, g, b = c.Red, c.Green, c.Blue
+	case string:
+		v, err := strconv.ParseUint(strings.TrimPrefix(c, "#"), 16, 32)
+		if err != nil || len(strings.TrimPrefix(c, "#")) != 6 {
+			return "", fmt.Errorf("colorName: invalid hex color %q", c)
+		}
+
+		r, g, b = float64(v>>16&0xff), float64(v>>8&0xff), float64(v&0xff)
+	default:
+		return "", fmt.Errorf("colorName: unsupported color %T", color)
+	}
+
+	name := ""
+	best := -1.0
+
+	for _, n := range namedColors {
+		d := (r-n.R)*(r-n.R) + (g-n.G)*(g-n.G) + (b-n.B)*(b-n.B)
+		if best < 0 || d < best {
+			name = n.Name
+			best = d
+		}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,571 @@
+// Package xmp updates the keywords and face regions of XMP
+// packets, keeping everything else in the packet as it is.
+//
+// Keywords are written as dc:subject, and as lr:hierarchicalSubject
+// under a root such as "visago|googlevision|mountain". Face regions
+// use the Metadata Working Group regions schema. Keywords and
+// regions found under the root are replaced on every update, the
+// ones written by other applications are kept. The dc:subject
+// keywords added under a root are recorded in visago:addedSubjects,
+// and only those are ever removed from dc:subject.
+package xmp
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"image"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Namespaces declared by the packets this package writes.
+const (
+	NamespaceDC     = "http://purl.org/dc/elements/1.1/"
+	NamespaceLR     = "http://ns.adobe.com/lightroom/1.0/"
+	NamespaceMWGRS  = "http://www.metadataworkinggroup.com/schemas/regions/"
+	NamespaceStDim  = "http://ns.adobe.com/xap/1.0/sType/Dimensions#"
+	NamespaceStArea = "http://ns.adobe.com/xmp/sType/Area#"
+	NamespaceVisago = "https://github.com/zquestz/visago/xmp/1.0/"
+)
+
+const emptyPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="">
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+
+var listItem = regexp.MustCompile(`<rdf:li[^>]*>([^<]*)</rdf:li>`)
+
+// Keyword is a tag and the plugin that found it.
+type Keyword struct {
+	Name   string
+	Source string
+}
+
+// Region is a face in pixels and the plugin that found it.
+type Region struct {
+	Rect   image.Rectangle
+	Source string
+}
+
+// Packet is an XMP packet.
+type Packet struct {
+	s string
+}
+
+// New returns an empty packet.
+func New() *Packet {
+	return &Packet{s: emptyPacket}
+}
+
+// Parse returns the packet in b. The packet must contain
+// an rdf:Description element.
+func Parse(b []byte) (*Packet, error) {
+	p := &Packet{s: string(b)}
+
+	if _, _, ok := p.description(); !ok {
+		return nil, fmt.Errorf("no rdf:Description in XMP packet")
+	}
+
+	return p, nil
+}
+
+// SidecarPath returns the sidecar file of file.
+func SidecarPath(file string) string {
+	return file + ".xmp"
+}
+
+// ReadSidecar returns the sidecar of file, or an empty
+// packet when there is no sidecar.
+func ReadSidecar(file string) (*Packet, error) {
+	b, err := ioutil.ReadFile(SidecarPath(file))
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(b)
+}
+
+// WriteSidecar writes p as the sidecar of file.
+func WriteSidecar(file string, p *Packet) error {
+	return ioutil.WriteFile(SidecarPath(file), p.Bytes(), 0644)
+}
+
+// Bytes returns the packet.
+func (p *Packet) Bytes() []byte {
+	return []byte(p.s)
+}
+
+// Keywords returns the dc:subject keywords of the packet.
+func (p *Packet) Keywords() []string {
+	return p.list("dc:subject")
+}
+
+// AddedKeywords returns the dc:subject keywords that were added
+// under root, and that did not exist before.
+func (p *Packet) AddedKeywords(root string) []string {
+	prefix := root + "|"
+
+	added := []string{}
+	for _, a := range p.list("visago:addedSubjects") {
+		if strings.HasPrefix(a, prefix) {
+			added = append(added, strings.TrimPrefix(a, prefix))
+		}
+	}
+
+	return added
+}
+
+// SetKeywords replaces the keywords previously written under root
+// with keywords. Only the dc:subject keywords added under root are
+// removed, so keywords that existed before the first update are
+// kept even when they are no longer written. Names in present,
+// such as the IPTC keywords of the same image, also count as
+// existing before.
+func (p *Packet) SetKeywords(root string, keywords []Keyword, present ...string) {
+	prefix := root + "|"
+
+	// Every root keeps its own record of the subjects it added.
+	added := make(map[string]bool)
+	addedElsewhere := make(map[string]bool)
+
+	records := []string{}
+	for _, a := range p.list("visago:addedSubjects") {
+		if strings.HasPrefix(a, prefix) {
+			added[strings.TrimPrefix(a, prefix)] = true
+			continue
+		}
+
+		if i := strings.LastIndex(a, "|"); i >= 0 {
+			addedElsewhere[a[i+1:]] = true
+		}
+
+		records = append(records, a)
+	}
+
+	existing := make(map[string]bool)
+	for _, name := range present {
+		existing[name] = true
+	}
+
+	wanted := make(map[string]bool)
+	for _, k := range keywords {
+		if k.Name != "" {
+			wanted[k.Name] = true
+		}
+	}
+
+	hierarchy := []string{}
+	for _, h := range p.list("lr:hierarchicalSubject") {
+		if !strings.HasPrefix(h, prefix) {
+			hierarchy = append(hierarchy, h)
+		}
+	}
+
+	subjects := []string{}
+	seen := make(map[string]bool)
+	for _, s := range p.list("dc:subject") {
+		if seen[s] || (added[s] && !wanted[s] && !addedElsewhere[s]) {
+			continue
+		}
+
+		seen[s] = true
+		subjects = append(subjects, s)
+	}
+
+	names := []string{}
+	paths := []string{}
+	for _, k := range keywords {
+		if k.Name == "" {
+			continue
+		}
+
+		if !seen[k.Name] {
+			seen[k.Name] = true
+			names = append(names, k.Name)
+
+			if !existing[k.Name] {
+				added[k.Name] = true
+			}
+		} else if addedElsewhere[k.Name] {
+			added[k.Name] = true
+		}
+
+		if k.Source != "" {
+			paths = append(paths, prefix+k.Source+"|"+k.Name)
+		} else {
+			paths = append(paths, prefix+k.Name)
+		}
+	}
+
+	sort.Strings(names)
+	subjects = append(subjects, names...)
+
+	for _, s := range subjects {
+		if added[s] && wanted[s] {
+			records = append(records, prefix+s)
+		}
+	}
+
+	hierarchy = append(hierarchy, paths...)
+	hierarchy = unique(hierarchy)
+
+	p.replace("dc:subject", bag("dc:subject", subjects), "dc", NamespaceDC)
+	p.replace("lr:hierarchicalSubject", bag("lr:hierarchicalSubject", hierarchy), "lr", NamespaceLR)
+	p.replace("visago:addedSubjects", bag("visago:addedSubjects", records), "visago", NamespaceVisago)
+}
+
+// SetFaceRegions replaces the face regions previously written under
+// root with regions, in an image of width by height pixels. Other
+// regions are kept.
+func (p *Packet) SetFaceRegions(root string, width, height int, regions []Region) {
+	items := []string{}
+
+	if start, end, ok := p.find("mwg-rs:Regions"); ok {
+		for _, item := range splitItems(p.s[start:end]) {
+			if !strings.Contains(item, `"`+root+"|") && !strings.Contains(item, ">"+root+"|") {
+				items = append(items, item)
+			}
+		}
+	}
+
+	if width > 0 && height > 0 {
+		for _, r := range regions {
+			rect := r.Rect.Intersect(image.Rect(0, 0, width, height))
+			if rect.Empty() {
+				continue
+			}
+
+			var buf bytes.Buffer
+			buf.WriteString("<rdf:li>\n       <rdf:Description mwg-rs:Type=\"Face\" mwg-rs:Description=\"")
+			xml.EscapeText(&buf, []byte(root+"|"+r.Source))
+			buf.WriteString("\">\n        <mwg-rs:Area")
+			fmt.Fprintf(&buf, " stArea:x=\"%s\"", normalized(float64(rect.Min.X+rect.Max.X)/2, width))
+			fmt.Fprintf(&buf, " stArea:y=\"%s\"", normalized(float64(rect.Min.Y+rect.Max.Y)/2, height))
+			fmt.Fprintf(&buf, " stArea:w=\"%s\"", normalized(float64(rect.Dx()), width))
+			fmt.Fprintf(&buf, " stArea:h=\"%s\"", normalized(float64(rect.Dy()), height))
+			buf.WriteString(" stArea:unit=\"normalized\"/>\n       </rdf:Description>\n      </rdf:li>")
+
+			items = append(items, buf.String())
+		}
+	}
+
+	if len(items) == 0 {
+		p.replace("mwg-rs:Regions", "")
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<mwg-rs:Regions rdf:parseType=\"Resource\">\n")
+	fmt.Fprintf(&buf, "    <mwg-rs:AppliedToDimensions stDim:w=\"%d\" stDim:h=\"%d\" stDim:unit=\"pixel\"/>\n", width, height)
+	buf.WriteString("    <mwg-rs:RegionList>\n     <rdf:Bag>\n")
+	for _, item := range items {
+		buf.WriteString("      ")
+		buf.WriteString(item)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("     </rdf:Bag>\n    </mwg-rs:RegionList>\n   </mwg-rs:Regions>")
+
+	p.replace("mwg-rs:Regions", buf.String(),
+		"mwg-rs", NamespaceMWGRS, "stDim", NamespaceStDim, "stArea", NamespaceStArea)
+}
+
+// list returns the unescaped rdf:li values of the named element.
+func (p *Packet) list(name string) []string {
+	values := []string{}
+
+	start, end, ok := p.find(name)
+	if !ok {
+		return values
+	}
+
+	for _, m := range listItem.FindAllStringSubmatch(p.s[start:end], -1) {
+		values = append(values, html.UnescapeString(strings.TrimSpace(m[1])))
+	}
+
+	return values
+}
+
+// replace replaces the named element with element. An empty
+// element removes it. Missing elements are added to the first
+// rdf:Description. namespaces holds prefix and URI pairs used by
+// element, which are declared on the rdf:Description the element
+// is added to, or on element itself, unless they are in scope.
+func (p *Packet) replace(name, element string, namespaces ...string) {
+	if start, end, ok := p.find(name); ok {
+		if element != "" {
+			element = p.declare(start, element, namespaces)
+		}
+
+		p.s = p.s[:start] + element + p.s[end:]
+		return
+	}
+
+	if element == "" {
+		return
+	}
+
+	start, end, ok := p.description()
+	if !ok {
+		return
+	}
+
+	tag := p.s[start:end]
+	if strings.HasSuffix(tag, "/>") {
+		tag = strings.TrimRight(strings.TrimSuffix(tag, "/>"), " \t\r\n") + ">"
+		p.s = p.s[:start] + tag + "\n  </rdf:Description>" + p.s[end:]
+		end = start + len(tag)
+	}
+
+	for i := 0; i+1 < len(namespaces); i += 2 {
+		if p.inScope(end, namespaces[i]) {
+			continue
+		}
+
+		decl := "\n    xmlns:" + namespaces[i] + "=\"" + namespaces[i+1] + "\""
+		p.s = p.s[:end-1] + decl + p.s[end-1:]
+		end += len(decl)
+	}
+
+	p.s = p.s[:end] + "\n   " + element + p.s[end:]
+}
+
+// declare adds the namespaces that are not in scope at pos
+// to the start tag of element.
+func (p *Packet) declare(pos int, element string, namespaces []string) string {
+	at := strings.IndexAny(element, " \t\r\n/>")
+	if at < 0 {
+		return element
+	}
+
+	decl := ""
+	for i := 0; i+1 < len(namespaces); i += 2 {
+		if !p.inScope(pos, namespaces[i]) {
+			decl += " xmlns:" + namespaces[i] + "=\"" + namespaces[i+1] + "\""
+		}
+	}
+
+	return element[:at] + decl + element[at:]
+}
+
+// inScope reports whether prefix is declared by an element that
+// is open at pos. Packets that cannot be read report false.
+func (p *Packet) inScope(pos int, prefix string) bool {
+	d := xml.NewDecoder(strings.NewReader(p.s[:pos]))
+	d.Strict = false
+
+	scopes := []map[string]bool{}
+	for {
+		t, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return false
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			declared := make(map[string]bool)
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					declared[a.Name.Local] = true
+				}
+			}
+
+			scopes = append(scopes, declared)
+		case xml.EndElement:
+			if len(scopes) > 0 {
+				scopes = scopes[:len(scopes)-1]
+			}
+		}
+	}
+
+	for _, declared := range scopes {
+		if declared[prefix] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// description returns the start tag of the first rdf:Description.
+func (p *Packet) description() (int, int, bool) {
+	start := tagIndex(p.s, "rdf:Description", 0)
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	end := tagEnd(p.s, start)
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+// find returns the first element with name, from its start
+// tag to the end of its end tag.
+func (p *Packet) find(name string) (int, int, bool) {
+	start := tagIndex(p.s, name, 0)
+	if start < 0 {
+		return 0, 0, false
+	}
+
+	end := tagEnd(p.s, start)
+	if end < 0 {
+		return 0, 0, false
+	}
+
+	if strings.HasSuffix(p.s[start:end], "/>") {
+		return start, end, true
+	}
+
+	closing := strings.Index(p.s[end:], "</"+name+">")
+	if closing < 0 {
+		return 0, 0, false
+	}
+
+	return start, end + closing + len("</"+name+">"), true
+}
+
+// tagIndex returns the index of the first start tag with name
+// at or after from, or -1.
+func tagIndex(s, name string, from int) int {
+	for {
+		i := strings.Index(s[from:], "<"+name)
+		if i < 0 {
+			return -1
+		}
+
+		i += from
+		next := i + len(name) + 1
+		if next < len(s) && strings.IndexByte(" \t\r\n/>", s[next]) >= 0 {
+			return i
+		}
+
+		from = next
+	}
+}
+
+// tagEnd returns the index after the tag starting at start,
+// skipping quoted attribute values, or -1.
+func tagEnd(s string, start int) int {
+	quote := byte(0)
+
+	for i := start; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i + 1
+		}
+	}
+
+	return -1
+}
+
+// splitItems returns the outermost rdf:li elements in s.
+func splitItems(s string) []string {
+	items := []string{}
+
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); {
+		if tagIndex(s[i:], "rdf:li", 0) == 0 {
+			end := tagEnd(s, i)
+			if end < 0 {
+				break
+			}
+
+			if depth == 0 {
+				start = i
+			}
+
+			if strings.HasSuffix(s[i:end], "/>") {
+				if depth == 0 {
+					items = append(items, s[start:end])
+				}
+			} else {
+				depth++
+			}
+
+			i = end
+			continue
+		}
+
+		if strings.HasPrefix(s[i:], "</rdf:li>") {
+			i += len("</rdf:li>")
+
+			depth--
+			if depth == 0 {
+				items = append(items, s[start:i])
+			}
+
+			continue
+		}
+
+		i++
+	}
+
+	return items
+}
+
+// bag returns the named element holding values in an rdf:Bag,
+// or an empty string when there are no values.
+func bag(name string, values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("<" + name + ">\n    <rdf:Bag>\n")
+	for _, v := range values {
+		buf.WriteString("     <rdf:li>")
+		xml.EscapeText(&buf, []byte(v))
+		buf.WriteString("</rdf:li>\n")
+	}
+	buf.WriteString("    </rdf:Bag>\n   </" + name + ">")
+
+	return buf.String()
+}
+
+func normalized(v float64, size int) string {
+	return strconv.FormatFloat(v/float64(size), 'f', 6, 64)
+}
+
+func unique(values []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,201 @@
+package xmp
+
+import (
+	"encoding/xml"
+	"image"
+	"io"
+	"strings"
+	"testing"
+)
+
+const userPacket = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>mountain</rdf:li>
+     <rdf:li>family</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`
+
+// multiPacket declares the namespaces visago writes on a second
+// rdf:Description only.
+const multiPacket = `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xmp="http://ns.adobe.com/xap/1.0/">
+   <xmp:Rating>3</xmp:Rating>
+  </rdf:Description>
+  <rdf:Description rdf:about=""
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:lr="http://ns.adobe.com/lightroom/1.0/"
+    xmlns:mwg-rs="http://www.metadataworkinggroup.com/schemas/regions/">
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>mountain</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+`
+
+func keywords(names ...string) []Keyword {
+	k := []Keyword{}
+	for _, name := range names {
+		k = append(k, Keyword{Name: name, Source: "test"})
+	}
+
+	return k
+}
+
+func checkKeywords(t *testing.T, step string, got []string, want ...string) {
+	t.Helper()
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s: keywords are %q, want %q", step, got, want)
+	}
+}
+
+func TestSetKeywordsRepeat(t *testing.T) {
+	p := New()
+
+	p.SetKeywords("visago", keywords("lake", "boat"))
+	checkKeywords(t, "first run", p.Keywords(), "boat", "lake")
+
+	b := string(p.Bytes())
+	p.SetKeywords("visago", keywords("lake", "boat"))
+	if string(p.Bytes()) != b {
+		t.Errorf("same keywords changed the packet:\n%s\nwant:\n%s", p.Bytes(), b)
+	}
+
+	p.SetKeywords("visago", keywords("boat", "sky"))
+	checkKeywords(t, "changed tags", p.Keywords(), "boat", "sky")
+	checkKeywords(t, "changed tags", p.AddedKeywords("visago"), "boat", "sky")
+
+	p.SetKeywords("visago", nil)
+	checkKeywords(t, "no tags", p.Keywords())
+	checkKeywords(t, "no tags", p.AddedKeywords("visago"))
+}
+
+func TestSetKeywordsKeepsUserKeywords(t *testing.T) {
+	p, err := Parse([]byte(userPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetKeywords("visago", keywords("mountain", "lake"))
+	checkKeywords(t, "first run", p.Keywords(), "mountain", "family", "lake")
+	checkKeywords(t, "first run", p.AddedKeywords("visago"), "lake")
+
+	p.SetKeywords("visago", keywords("lake"))
+	checkKeywords(t, "second run", p.Keywords(), "mountain", "family", "lake")
+
+	// A keyword added by the user between runs.
+	p, err = Parse([]byte(strings.Replace(string(p.Bytes()),
+		"<rdf:li>family</rdf:li>", "<rdf:li>family</rdf:li>\n     <rdf:li>beach</rdf:li>", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetKeywords("visago", nil)
+	checkKeywords(t, "no tags", p.Keywords(), "mountain", "family", "beach")
+}
+
+func TestSetKeywordsPresent(t *testing.T) {
+	p := New()
+
+	p.SetKeywords("visago", keywords("mountain", "lake"), "mountain")
+	checkKeywords(t, "first run", p.Keywords(), "lake", "mountain")
+	checkKeywords(t, "first run", p.AddedKeywords("visago"), "lake")
+
+	p.SetKeywords("visago", nil)
+	checkKeywords(t, "no tags", p.Keywords(), "mountain")
+}
+
+func TestSetKeywordsRoots(t *testing.T) {
+	p := New()
+
+	p.SetKeywords("a", keywords("lake"))
+	p.SetKeywords("b", keywords("lake"))
+
+	p.SetKeywords("a", nil)
+	checkKeywords(t, "root a cleared", p.Keywords(), "lake")
+
+	p.SetKeywords("b", nil)
+	checkKeywords(t, "both roots cleared", p.Keywords())
+}
+
+// checkNamespaces fails when an element or attribute of the
+// packet uses a prefix that is not declared.
+func checkNamespaces(t *testing.T, p *Packet) {
+	t.Helper()
+
+	bound := func(name xml.Name) bool {
+		return name.Space == "" || name.Space == "xmlns" || strings.Contains(name.Space, "/")
+	}
+
+	d := xml.NewDecoder(strings.NewReader(string(p.Bytes())))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+
+		if err != nil {
+			t.Fatalf("invalid packet: %s\n%s", err, p.Bytes())
+		}
+
+		if e, ok := tok.(xml.StartElement); ok {
+			if !bound(e.Name) {
+				t.Fatalf("prefix %s is not declared:\n%s", e.Name.Space, p.Bytes())
+			}
+
+			for _, a := range e.Attr {
+				if !bound(a.Name) {
+					t.Fatalf("prefix %s is not declared:\n%s", a.Name.Space, p.Bytes())
+				}
+			}
+		}
+	}
+}
+
+func TestMultipleDescriptions(t *testing.T) {
+	p, err := Parse([]byte(multiPacket))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetKeywords("visago", keywords("lake"))
+	p.SetFaceRegions("visago", 100, 100, []Region{{Rect: image.Rect(10, 10, 40, 40), Source: "test"}})
+	checkNamespaces(t, p)
+	checkKeywords(t, "first run", p.Keywords(), "mountain", "lake")
+	checkKeywords(t, "first run", p.AddedKeywords("visago"), "lake")
+
+	b := string(p.Bytes())
+	p.SetKeywords("visago", keywords("lake"))
+	p.SetFaceRegions("visago", 100, 100, []Region{{Rect: image.Rect(10, 10, 40, 40), Source: "test"}})
+	checkNamespaces(t, p)
+	if string(p.Bytes()) != b {
+		t.Errorf("same keywords changed the packet:\n%s\nwant:\n%s", p.Bytes(), b)
+	}
+
+	p.SetKeywords("visago", nil)
+	p.SetFaceRegions("visago", 100, 100, nil)
+	checkNamespaces(t, p)
+	checkKeywords(t, "no tags", p.Keywords(), "mountain")
+}
+
+func TestNewPacketNamespaces(t *testing.T) {
+	p := New()
+
+	p.SetKeywords("visago", keywords("lake"))
+	p.SetFaceRegions("visago", 100, 100, []Region{{Rect: image.Rect(10, 10, 40, 40), Source: "test"}})
+	checkNamespaces(t, p)
+}